@@ -1,70 +1,259 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"image"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/stianeikeland/go-rpio"
 
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/behavior"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/calibration"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/control"
 	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/detector"
 	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/drawer"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/frame"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/laser"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/metrics"
 	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/mjpeg"
 	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/params"
 	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/raspivid"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/recorder"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/safety"
 	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/servo"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/simulator"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/source"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/telemetry"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/tracker"
 )
 
 // LastState of detector
 type LastState struct {
 	sync.Mutex
 
-	Img         image.RGBA     // previous analyzed image
-	DotPoint    image.Point    // current dot position from servo field controller
-	MotionPoint detector.Point // previous detected motion point
+	DotPoint          image.Point     // laser dot position found on the image (commanded if it's not found)
+	DotConfidence     float64         // confidence of the found laser dot, 0 - commanded position is used
+	CommandedDotPoint image.Point     // current dot position from servo field controller
+	MotionPoint       detector.Point  // previous detected motion point
+	Latency           time.Duration   // time from frame capture to the last servo command
+	Tracks            []tracker.Track // tracked motion objects
+
+	CommandedDot servo.PercentPoint // current dot position in servo field coordinates
 }
 
+// shutdownTimeout is max time of each graceful shutdown step
+var shutdownTimeout = 5 * time.Second
+
 func errorAndExit(err error) {
 	fmt.Println(err)
 	os.Exit(1)
 }
 
-func startRaspividStream(w, h, fps int, flipH, flipV bool) (chan []byte, error) {
+func newRaspividStream(w, h, fps int, flipH, flipV bool, backendName, command string) (*raspivid.ImageStream, error) {
+	backend, err := raspivid.NewBackend(backendName, command)
+	if err != nil {
+		return nil, err
+	}
+
 	options := []string{
 		//"--saturation", "-100", // set image saturation (-100 to 100), -100 for grayscale
 		//"--annotate", "12", // add timestamp (enable/set annotate flags or text)
 	}
 
-	if flipH {
-		options = append(options, "--hflip") // set horizontal flip
-	}
-	if flipV {
-		options = append(options, "--vflip") // set vertical flip
-	}
-
-	raspividImageStream := &raspivid.ImageStream{
+	return &raspivid.ImageStream{
 		FPS:     fps,
 		Width:   w,
 		Height:  h,
+		FlipH:   flipH,
+		FlipV:   flipV,
 		Options: options,
+		Backend: backend,
+	}, nil
+}
+
+func createDetector(
+	name string,
+	threshold uint32,
+	learningRate float64,
+	blobFilter detector.BlobFilter,
+	region *detector.Mask,
+) (detector.Detector, error) {
+	switch name {
+	case "diff":
+		return &detector.FrameDiff{
+			Threshold:  threshold,
+			BlobFilter: blobFilter,
+			Region:     region,
+		}, nil
+	case "background":
+		return &detector.Background{
+			Threshold:    threshold,
+			LearningRate: learningRate,
+			BlobFilter:   blobFilter,
+			Region:       region,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown detector '%s', use: diff or background", name)
+	}
+}
+
+// configureDetector changes detector sensitivity, detector state (ex. background model) is kept
+func configureDetector(d detector.Detector, threshold uint32, learningRate float64, blobFilter detector.BlobFilter) {
+	switch d := d.(type) {
+	case *detector.FrameDiff:
+		d.Threshold = threshold
+		d.BlobFilter = blobFilter
+	case *detector.Background:
+		d.Threshold = threshold
+		d.LearningRate = learningRate
+		d.BlobFilter = blobFilter
+	}
+}
+
+// selectTarget chooses a motion blob to run away from
+func selectTarget(target string, blobs []detector.Blob, dotPoint image.Point) (detector.Point, bool) {
+	var blob detector.Blob
+	var ok bool
+	if target == "nearest" {
+		blob, ok = detector.NearestBlob(blobs, dotPoint.X, dotPoint.Y)
+	} else {
+		blob, ok = detector.LargestBlob(blobs)
+	}
+	return blob.Point(), ok
+}
+
+// telemetryRecord creates telemetry of the frame with detections and tracks in percent of the image
+func telemetryRecord(f frame.Frame, blobs []detector.Blob, tracks []tracker.Track, w, h int) telemetry.Record {
+	record := telemetry.Record{
+		Time:       f.Time,
+		Source:     f.Source,
+		Seq:        f.Seq,
+		Detections: make([]telemetry.Detection, len(blobs)),
+		Tracks:     make([]telemetry.Track, len(tracks)),
+	}
+	for i, blob := range blobs {
+		record.Detections[i] = telemetry.Detection{
+			X:      float64(blob.X) / float64(w),
+			Y:      float64(blob.Y) / float64(h),
+			Width:  float64(blob.Rect.X1-blob.Rect.X0) / float64(w),
+			Height: float64(blob.Rect.Y1-blob.Rect.Y0) / float64(h),
+			Pixels: blob.Pixels,
+		}
+	}
+	for i, track := range tracks {
+		record.Tracks[i] = telemetry.Track{
+			ID: track.ID,
+			X:  track.X,
+			Y:  track.Y,
+			VX: track.VX,
+			VY: track.VY,
+		}
+	}
+	return record
+}
+
+// polygonPoints converts polygon in percent to image points
+func polygonPoints(polygon detector.Polygon, w, h int) []image.Point {
+	points := make([]image.Point, len(polygon))
+	for i, p := range polygon {
+		points[i] = image.Point{
+			X: int(p.X * float64(w)),
+			Y: int(p.Y * float64(h)),
+		}
+	}
+	return points
+}
+
+// zonePoints converts keep-out zone in servo field coordinates to image points
+func zonePoints(zone servo.Zone, cameraToLaser *calibration.Calibration, w, h int) []image.Point {
+	points := make([]image.Point, len(zone))
+	for i, p := range zone {
+		x, y := cameraToLaser.ToImage(p.X, p.Y)
+		points[i] = image.Point{
+			X: int(x * float64(w)),
+			Y: int(y * float64(h)),
+		}
+	}
+	return points
+}
+
+// roomLaserPin connects the laser to the simulator room
+type roomLaserPin struct {
+	room *simulator.Room
+}
+
+func (p roomLaserPin) High() {
+	p.room.SetLaser(true)
+}
+
+func (p roomLaserPin) Low() {
+	p.room.SetLaser(false)
+}
+
+func createLaserPin(simulate bool, pin int, room *simulator.Room) laser.Pin {
+	if room != nil {
+		return roomLaserPin{room: room}
+	}
+	if simulate || pin == 0 {
+		return laser.NewMemoryPin()
+	}
+
+	rpioPin := rpio.Pin(pin)
+	rpioPin.Output()
+
+	return rpioPin
+}
+
+func createServos(simulate bool, xMin, xMax, yMin, yMax uint32) (servoX, servoY servo.Actuator, err error) {
+	if simulate {
+		return servo.NewMemoryActuator(), servo.NewMemoryActuator(), nil
 	}
 
-	raspividImageCh, err := raspividImageStream.Start()
+	// create servo X
+	servoX, err = servo.NewServo(params.ServoXPin, xMin, xMax)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return raspividImageCh, nil
+	// create servos Y
+	servoY, err = servo.NewServo(params.ServoYPin, yMin, yMax)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return servoX, servoY, nil
 }
 
 func main() {
 	var (
 		fDebug = flag.Bool("debug", false, "print fps to output")
 
+		fSimulate = flag.Bool(
+			"simulate",
+			false,
+			"run without RPi hardware: virtual servos and synthetic camera images (unless other source is set)",
+		)
+
+		fSourceFile  = flag.String("source-file", "", "replay recorded MJPEG file instead of camera")
+		fSourceDir   = flag.String("source-dir", "", "replay directory of JPEG images instead of camera")
+		fSourceURL   = flag.String("source-url", "", "pull remote MJPEG stream instead of camera")
+		fSourceLoop  = flag.Bool("source-loop", false, "start file/directory replay over after the last image")
+		fSourceSpeed = flag.Float64(
+			"source-speed",
+			1,
+			"file/directory replay speed relative to camera fps (0 - as fast as possible)",
+		)
+
 		fCameraFPS   = flag.Int("camera-fps", params.CameraFPS, "camera fps")
 		fCameraFlipH = flag.Bool("camera-flip-h", false, "flip camera image horizontally")
 		fCameraFlipV = flag.Bool("camera-flip-v", false, "flip camera image vertical")
@@ -74,6 +263,34 @@ func main() {
 			"camera resolution scale (128*scale x 96*scale)",
 		)
 
+		fCameraBackend = flag.String(
+			"camera-backend",
+			raspivid.BackendAuto,
+			"camera program: auto, raspivid or libcamera (rpicam-vid/libcamera-vid)",
+		)
+		fCameraCommand = flag.String("camera-command", "", "camera program path (overrides backend default)")
+
+		fCalibration = flag.String(
+			"calibration",
+			params.CalibrationFile,
+			"camera to laser calibration file (empty - servo range matches camera frame)",
+		)
+		fCalibrate = flag.Bool(
+			"calibrate",
+			false,
+			"run camera to laser calibration, save it to -calibration file and exit",
+		)
+		fCalibrationGrid = flag.Int(
+			"calibration-grid",
+			params.CalibrationGrid,
+			"number of calibration points per axis",
+		)
+		fCalibrationSettle = flag.Int(
+			"calibration-settle",
+			params.CalibrationSettle,
+			"time for servos to reach calibration point in milliseconds",
+		)
+
 		fServoXFlip = flag.Bool("servo-flip-x", false, "flip servo x position calculation")
 		fServoYFlip = flag.Bool("servo-flip-y", false, "flip servo y position calculation")
 		fServoXMin  = flag.Int(
@@ -97,8 +314,123 @@ func main() {
 			"servo y max angle pulse length ~[20-120]",
 		)
 
+		fKeepOut = flag.String(
+			"keep-out",
+			params.KeepOutFile,
+			"file with laser keep-out zones in servo field coordinates [0-1] (sofa, mirror, eyes level)",
+		)
+
+		fLaserPin = flag.Int(
+			"laser-pin",
+			params.LaserPin,
+			"laser GPIO (BCM) pin number (0 - laser is not controlled, always on)",
+		)
+		fLaserPattern = flag.String(
+			"laser-pattern",
+			params.LaserPattern,
+			"laser pattern: steady, blink, flicker or heartbeat",
+		)
+		fLaserMaxOnTime = flag.Int(
+			"laser-max-on-time",
+			params.LaserMaxOnTime,
+			"max continuous laser on time in milliseconds (0 - no limit)",
+		)
+		fLaserCoolDown = flag.Int(
+			"laser-cool-down",
+			params.LaserCoolDown,
+			"laser off time after max on time is reached in milliseconds",
+		)
+
+		fParkX = flag.Float64("park-x", params.ParkX, "servo x park position on exit [0-1]")
+		fParkY = flag.Float64("park-y", params.ParkY, "servo y park position on exit [0-1]")
+
 		fStream     = flag.Bool("stream", false, "stream debug image")
-		fStreamPort = flag.String("stream-port", params.StreamPort, "stream port, url: IP:PORT/stream)")
+		fStreamPort = flag.String("stream-port", params.StreamPort, "HTTP server port, url: IP:PORT/stream")
+
+		fHTTP = flag.Bool(
+			"http",
+			false,
+			"start HTTP server for telemetry and metrics without the stream (IP:PORT/events, IP:PORT/metrics)",
+		)
+		fHTTPAddr = flag.String(
+			"http-addr",
+			params.HTTPAddr,
+			"HTTP server bind address (empty - all interfaces, 127.0.0.1 - local access only)",
+		)
+		fHTTPControl = flag.Bool(
+			"http-control",
+			false,
+			"serve control endpoints: /behavior, /settings, /record and /clips (no authentication)",
+		)
+
+		fRecord = flag.Bool(
+			"record",
+			false,
+			"start session recording (can be toggled at runtime with -http-control: IP:PORT/record)",
+		)
+		fRecordDir     = flag.String("record-dir", params.RecordDir, "directory for session recordings")
+		fRecordStreams = flag.String(
+			"record-streams",
+			params.RecordStreams,
+			"recorded streams, comma separated: raw (camera frames) and/or debug (debug frames)",
+		)
+		fRecordFormat = flag.String(
+			"record-format",
+			params.RecordFormat,
+			"recorded video format: mjpeg (replay with -source-file) or avi (for video players)",
+		)
+		fRecordSegment = flag.Int(
+			"record-segment",
+			params.RecordSegment,
+			"recording files rotation interval in seconds",
+		)
+		fRecordMaxFileSize = flag.Int(
+			"record-max-file-size",
+			params.RecordMaxFileSize,
+			"recording files rotation size in MB",
+		)
+		fRecordQuota = flag.Int(
+			"record-quota",
+			params.RecordQuota,
+			"max size of all recordings in MB, the oldest are removed (0 - no limit)",
+		)
+
+		fClips = flag.Bool(
+			"clips",
+			false,
+			"record clips of motion events with pre-roll and post-roll (list with -http-control: IP:PORT/clips)",
+		)
+		fClipsDir     = flag.String("clips-dir", params.ClipsDir, "directory for motion event clips")
+		fClipsMinArea = flag.Float64(
+			"clips-min-area",
+			params.ClipsMinArea,
+			"motion area to start a clip, as percent of the image [0-1]",
+		)
+		fClipsMinDuration = flag.Int(
+			"clips-min-duration",
+			params.ClipsMinDuration,
+			"motion duration to start a clip in milliseconds",
+		)
+		fClipsPreRoll = flag.Int(
+			"clips-pre-roll",
+			params.ClipsPreRoll,
+			"seconds of video before the motion start",
+		)
+		fClipsPostRoll = flag.Int(
+			"clips-post-roll",
+			params.ClipsPostRoll,
+			"seconds of video after the motion end",
+		)
+		fClipsMaxDuration = flag.Int(
+			"clips-max-duration",
+			params.ClipsMaxDuration,
+			"max clip duration in seconds, longer events are split into several clips",
+		)
+		fClipsQuota = flag.Int(
+			"clips-quota",
+			params.ClipsQuota,
+			"max size of all clips in MB, the oldest are removed (0 - no limit)",
+		)
 
 		fLaserRunAwayRadius = flag.Float64(
 			"run-away-radius",
@@ -110,17 +442,123 @@ func main() {
 			params.AlwaysStayOnRunAwayRadius,
 			"laser stays on run away radius",
 		)
+		fRunAwayStrategy = flag.String(
+			"run-away",
+			params.RunAwayStrategy,
+			"run away strategy: geometric (from current position) or predictive (from predicted path)",
+		)
+		fRunAwayLatency = flag.Int(
+			"run-away-latency",
+			params.RunAwayLatency,
+			"servo latency in milliseconds to predict motion position (for predictive run away)",
+		)
+
+		fBehavior = flag.String(
+			"behavior",
+			params.Behavior,
+			"laser behavior: run-away, lure, wander or tease (can be switched at runtime with -http-control: IP:PORT/behavior)",
+		)
+		fLureDistance = flag.Float64(
+			"lure-distance",
+			params.LureDistance,
+			"lure behavior: dot distance in front of the motion as percent of width [0-1]",
+		)
+		fTeaseNearDistance = flag.Float64(
+			"tease-near-distance",
+			params.TeaseNearDistance,
+			"tease behavior: approach distance to dart away as percent of width [0-1]",
+		)
+		fTeaseDartDistance = flag.Float64(
+			"tease-dart-distance",
+			params.TeaseDartDistance,
+			"tease behavior: dart away distance as percent of width [0-1]",
+		)
+		fTeaseDartTime = flag.Int(
+			"tease-dart-time",
+			params.TeaseDartTime,
+			"tease behavior: time to stay away after dart in milliseconds",
+		)
+		fTeaseHideTime = flag.Int(
+			"tease-hide-time",
+			params.TeaseHideTime,
+			"tease behavior: the dot disappears after dart for this time in milliseconds (needs -laser-pin)",
+		)
+		fWanderAmplitude = flag.Float64(
+			"wander-amplitude",
+			params.WanderAmplitude,
+			"wander behavior: max move distance as percent of width [0-1]",
+		)
+		fWanderInterval = flag.Int(
+			"wander-interval",
+			params.WanderInterval,
+			"wander behavior: time between moves in milliseconds",
+		)
 
+		fDetector = flag.String(
+			"detector",
+			params.Detector,
+			"motion detector: diff (frame differencing) or background (background subtraction)",
+		)
+		fDetectorLearningRate = flag.Float64(
+			"detector-learning-rate",
+			params.DetectorLearningRate,
+			"background detector model learning rate [0-1]",
+		)
+		fDetectorMask = flag.String(
+			"detector-mask",
+			params.DetectorMaskFile,
+			"detector region file with include/exclude polygons and bitmap mask (empty - whole image)",
+		)
 		fDetectorThreshold = flag.Int(
 			"detector-threshold",
 			params.DetectorThreshold,
 			"detector sensitivity threshold",
 		)
+		fDetectorMinArea = flag.Int(
+			"detector-min-area",
+			params.DetectorMinArea,
+			"min motion blob bounding box area in pixels",
+		)
+		fDetectorMaxArea = flag.Int(
+			"detector-max-area",
+			params.DetectorMaxArea,
+			"max motion blob bounding box area in pixels (0 - no limit)",
+		)
+		fDetectorMinAspectRatio = flag.Float64(
+			"detector-min-aspect-ratio",
+			params.DetectorMinAspectRatio,
+			"min motion blob width to height ratio (0 - no limit)",
+		)
+		fDetectorMaxAspectRatio = flag.Float64(
+			"detector-max-aspect-ratio",
+			params.DetectorMaxAspectRatio,
+			"max motion blob width to height ratio (0 - no limit)",
+		)
+		fDetectorTarget = flag.String(
+			"detector-target",
+			params.DetectorTarget,
+			"motion blob to run away from: largest or nearest (to the laser dot)",
+		)
+		fTrackerMaxDistance = flag.Float64(
+			"tracker-max-distance",
+			params.TrackerMaxDistance,
+			"max distance to associate motion with a track as percent of view area [0-1]",
+		)
+		fTrackerTimeout = flag.Int(
+			"tracker-timeout",
+			params.TrackerTimeout,
+			"lost track expiration in milliseconds",
+		)
 		fDetectorBlindSpotRadius = flag.Int(
 			"detector-blind-spot-radius",
 			params.DetectorBlindSpotRadius,
 			"detector blind spot radius (to prevent self-detection)",
 		)
+		fLaserDotMinConfidence = flag.Float64(
+			"laser-dot-min-confidence",
+			params.LaserDotMinConfidence,
+			"min confidence of the laser dot found on the image to use instead of commanded position [0-1]",
+		)
 
 		fRandomAmplitude = flag.Float64(
 			"ramdom-amplitude",
@@ -133,6 +571,9 @@ func main() {
 			"laser random movements interval in seconds (0 to disable)",
 		)
 
+		fConfig  = flag.String("config", params.ConfigFile, "JSON config file with options and profiles")
+		fProfile = flag.String("profile", params.Profile, "config file profile to apply over default options")
+
 		fVersion = flag.Bool("version", false, "print version")
 	)
 
@@ -143,24 +584,73 @@ func main() {
 		os.Exit(0)
 	}
 
-	// prepare RPi GPIO hardware
-	err := rpio.Open()
+	// options precedence: command line, environment, config file profile, config file default, built-in
+	explicitOptions := params.CommandLineFlags(flag.CommandLine)
+	envOptions, err := params.ApplyEnv(flag.CommandLine, explicitOptions)
 	if err != nil {
 		errorAndExit(err)
 	}
-	defer rpio.Close()
+	for name := range envOptions {
+		explicitOptions[name] = true
+	}
+	if *fConfig != "" {
+		config, err := params.LoadConfig(*fConfig)
+		if err != nil {
+			errorAndExit(err)
+		}
+		if err := config.Apply(flag.CommandLine, *fProfile, explicitOptions); err != nil {
+			errorAndExit(err)
+		}
+		if *fProfile != "" {
+			fmt.Printf("[Main] config is loaded: %s (profile: %s)\n", *fConfig, *fProfile)
+		} else {
+			fmt.Printf("[Main] config is loaded: %s\n", *fConfig)
+		}
+	} else if *fProfile != "" {
+		errorAndExit(fmt.Errorf("config file is not set, use: -config file.json -profile %s", *fProfile))
+	}
+	if err := params.Validate(flag.CommandLine); err != nil {
+		errorAndExit(err)
+	}
+	if *fHTTPControl && !*fStream && !*fHTTP {
+		errorAndExit(fmt.Errorf("HTTP server is not started, use: -http -http-control or -stream -http-control"))
+	}
 
-	rpio.StartPwm()
-	defer rpio.StopPwm()
+	// load camera to laser calibration
+	cameraToLaser := calibration.Default()
+	if *fCalibrate {
+		if *fCalibration == "" {
+			errorAndExit(fmt.Errorf("calibration file is not set, use: -calibration file.json"))
+		}
+	} else if *fCalibration != "" {
+		var err error
+		cameraToLaser, err = calibration.Load(*fCalibration)
+		if err != nil {
+			errorAndExit(err)
+		}
+		fmt.Printf("[Main] calibration is loaded: %s (error: %.4f)\n", *fCalibration, cameraToLaser.Error)
+	}
 
-	// create servo X
-	servoX, err := servo.NewServo(params.ServoXPin, uint32(*fServoXMin), uint32(*fServoXMax))
-	if err != nil {
-		errorAndExit(err)
+	// prepare RPi GPIO hardware
+	if !*fSimulate {
+		err := rpio.Open()
+		if err != nil {
+			errorAndExit(err)
+		}
+		defer rpio.Close()
+
+		rpio.StartPwm()
+		defer rpio.StopPwm()
 	}
 
-	// create servos Y
-	servoY, err := servo.NewServo(params.ServoYPin, uint32(*fServoYMin), uint32(*fServoYMax))
+	// create servos
+	servoX, servoY, err := createServos(
+		*fSimulate,
+		uint32(*fServoXMin),
+		uint32(*fServoXMax),
+		uint32(*fServoYMin),
+		uint32(*fServoYMax),
+	)
 	if err != nil {
 		errorAndExit(err)
 	}
@@ -168,40 +658,455 @@ func main() {
 	// create servos XY field
 	servoFieldXY := servo.NewFieldXY(servoX, servoY, *fServoXFlip, *fServoYFlip)
 
+	// laser never goes to keep-out zones
+	if *fKeepOut != "" {
+		zones, err := servo.LoadZones(*fKeepOut)
+		if err != nil {
+			errorAndExit(err)
+		}
+		servoFieldXY.SetKeepOut(zones)
+		fmt.Printf("[Main] keep-out zones are loaded: %s (%d zones)\n", *fKeepOut, len(zones))
+	}
+
 	// generate random laser dot movements
-	if *fRandomInterval > 0 {
+	if *fRandomInterval > 0 && !*fCalibrate {
 		servoFieldXY.SetRandomMovements(*fRandomAmplitude, time.Second*time.Duration(*fRandomInterval))
 	}
 
 	cameraWidth := params.CameraMinWidth * *fCameraScale
 	cameraHeight := params.CameraMinHeight * *fCameraScale
 
-	// start raspivid stream
-	raspividImageCh, err := startRaspividStream(cameraWidth, cameraHeight, *fCameraFPS, *fCameraFlipH, *fCameraFlipV)
+	// load region to detect motion in
+	var detectorRegion *detector.Region
+	var detectorRegionMask *detector.Mask
+	if *fDetectorMask != "" {
+		detectorRegion, err = detector.LoadRegion(*fDetectorMask)
+		if err != nil {
+			errorAndExit(err)
+		}
+		detectorRegionMask = detectorRegion.Mask(cameraWidth, cameraHeight)
+	}
+
+	// create motion detector
+	detectorBlobFilter := func() detector.BlobFilter {
+		return detector.BlobFilter{
+			MinArea:        *fDetectorMinArea,
+			MaxArea:        *fDetectorMaxArea,
+			MinAspectRatio: *fDetectorMinAspectRatio,
+			MaxAspectRatio: *fDetectorMaxAspectRatio,
+		}
+	}
+	motionDetector, err := createDetector(
+		*fDetector,
+		uint32(*fDetectorThreshold),
+		*fDetectorLearningRate,
+		detectorBlobFilter(),
+		detectorRegionMask,
+	)
 	if err != nil {
 		errorAndExit(err)
 	}
 
+	// create laser behaviors, the active one can be switched at runtime
+	runAwayBehavior := &behavior.RunAway{
+		Radius:             *fLaserRunAwayRadius,
+		AlwaysStayOnRadius: *fFollow,
+		Predictive:         *fRunAwayStrategy == "predictive",
+		Latency:            time.Millisecond * time.Duration(*fRunAwayLatency),
+	}
+	lureBehavior := &behavior.Lure{
+		Distance: *fLureDistance,
+		Lead:     time.Millisecond * time.Duration(*fRunAwayLatency),
+	}
+	behaviors := behavior.NewSwitch(
+		runAwayBehavior,
+		lureBehavior,
+		&behavior.Wander{
+			Interval:  time.Millisecond * time.Duration(*fWanderInterval),
+			Amplitude: *fWanderAmplitude,
+			Margin:    0.05,
+		},
+		&behavior.Tease{
+			NearDistance: *fTeaseNearDistance,
+			DartDistance: *fTeaseDartDistance,
+			DartTime:     time.Millisecond * time.Duration(*fTeaseDartTime),
+			HideTime:     time.Millisecond * time.Duration(*fTeaseHideTime),
+		},
+	)
+	if err := behaviors.Set(*fBehavior); err != nil {
+		errorAndExit(err)
+	}
+
+	// options that can be changed at runtime, the frame loop holds the lock while processing a frame
+	settings := &control.Settings{
+		FlagSet: flag.CommandLine,
+		Names: []string{
+			"detector-threshold",
+			"detector-learning-rate",
+			"detector-min-area",
+			"detector-max-area",
+			"detector-min-aspect-ratio",
+			"detector-max-aspect-ratio",
+			"detector-target",
+			"detector-blind-spot-radius",
+			"run-away-radius",
+			"follow",
+			"run-away",
+			"run-away-latency",
+			"ramdom-amplitude",
+			"random-interval",
+			"servo-x-min",
+			"servo-x-max",
+			"servo-y-min",
+			"servo-y-max",
+			"servo-flip-x",
+			"servo-flip-y",
+		},
+		Validate: params.Validate,
+		Apply: func(changed []string) {
+			isChanged := map[string]bool{}
+			for _, name := range changed {
+				isChanged[name] = true
+			}
+
+			configureDetector(
+				motionDetector,
+				uint32(*fDetectorThreshold),
+				*fDetectorLearningRate,
+				detectorBlobFilter(),
+			)
+
+			runAwayBehavior.Radius = *fLaserRunAwayRadius
+			runAwayBehavior.AlwaysStayOnRadius = *fFollow
+			runAwayBehavior.Predictive = *fRunAwayStrategy == "predictive"
+			runAwayBehavior.Latency = time.Millisecond * time.Duration(*fRunAwayLatency)
+			lureBehavior.Lead = runAwayBehavior.Latency
+
+			if isChanged["ramdom-amplitude"] || isChanged["random-interval"] {
+				amplitude := *fRandomAmplitude
+				if *fRandomInterval == 0 {
+					amplitude = 0
+				}
+				servoFieldXY.SetRandomMovements(amplitude, time.Second*time.Duration(*fRandomInterval))
+			}
+
+			if s, ok := servoX.(*servo.Servo); ok && (isChanged["servo-x-min"] || isChanged["servo-x-max"]) {
+				s.SetRange(uint32(*fServoXMin), uint32(*fServoXMax))
+			}
+			if s, ok := servoY.(*servo.Servo); ok && (isChanged["servo-y-min"] || isChanged["servo-y-max"]) {
+				s.SetRange(uint32(*fServoYMin), uint32(*fServoYMax))
+			}
+			if isChanged["servo-flip-x"] || isChanged["servo-flip-y"] {
+				servoFieldXY.SetFlip(*fServoXFlip, *fServoYFlip)
+			}
+		},
+	}
+
+	laserPattern, err := laser.PatternByName(*fLaserPattern)
+	if err != nil {
+		errorAndExit(err)
+	}
+	if *fCalibrate {
+		laserPattern = nil
+	}
+
+	// session recording of camera and debug frames with telemetry, can be toggled at runtime
+	sessionRecorder := &recorder.Recorder{
+		Dir:             *fRecordDir,
+		Streams:         strings.Split(*fRecordStreams, ","),
+		Format:          *fRecordFormat,
+		Width:           cameraWidth,
+		Height:          cameraHeight,
+		FPS:             *fCameraFPS,
+		SegmentDuration: time.Second * time.Duration(*fRecordSegment),
+		MaxFileSize:     int64(*fRecordMaxFileSize) * 1024 * 1024,
+		Quota:           int64(*fRecordQuota) * 1024 * 1024,
+	}
+	if err := sessionRecorder.Validate(); err != nil {
+		errorAndExit(err)
+	}
+	if *fRecord && !*fCalibrate {
+		if err := sessionRecorder.Start(); err != nil {
+			errorAndExit(err)
+		}
+	}
+
+	// motion event clips from camera frames
+	eventRecorder := &recorder.EventRecorder{
+		Dir:         *fClipsDir,
+		Width:       cameraWidth,
+		Height:      cameraHeight,
+		FPS:         *fCameraFPS,
+		MinArea:     *fClipsMinArea,
+		MinDuration: time.Millisecond * time.Duration(*fClipsMinDuration),
+		PreRoll:     time.Second * time.Duration(*fClipsPreRoll),
+		PostRoll:    time.Second * time.Duration(*fClipsPostRoll),
+		MaxDuration: time.Second * time.Duration(*fClipsMaxDuration),
+		Quota:       int64(*fClipsQuota) * 1024 * 1024,
+	}
+	if *fClips && !*fCalibrate {
+		if err := eventRecorder.Start(); err != nil {
+			errorAndExit(err)
+		}
+	}
+
+	// application metrics in Prometheus text format
+	metricsRegistry := metrics.NewRegistry()
+	framesReceived := metricsRegistry.NewCounter(
+		"laser_cat_frames_received_total",
+		"Frames received from the frame source.",
+	)
+	framesDecoded := metricsRegistry.NewCounter(
+		"laser_cat_frames_decoded_total",
+		"Frames decoded from JPEG.",
+	)
+	metricsRegistry.NewLabeledCounterFunc(
+		"laser_cat_frames_dropped_total",
+		"Frames dropped in the pipeline by stage.",
+		"stage",
+		func() map[string]float64 {
+			values := map[string]float64{}
+			for stage, count := range frame.Drops.Dropped() {
+				values[stage] = float64(count)
+			}
+			return values
+		},
+	)
+	frameLatency := metricsRegistry.NewHistogram(
+		"laser_cat_frame_latency_seconds",
+		"Time from frame capture to the end of processing.",
+		metrics.DurationBuckets,
+	)
+	detectorDuration := metricsRegistry.NewHistogram(
+		"laser_cat_detector_duration_seconds",
+		"Motion detection time per frame.",
+		metrics.DurationBuckets,
+	)
+	jpegEncodeDuration := metricsRegistry.NewHistogram(
+		"laser_cat_jpeg_encode_duration_seconds",
+		"Debug image JPEG encoding time.",
+		metrics.DurationBuckets,
+	)
+	behaviorTriggers := metricsRegistry.NewCounterVec(
+		"laser_cat_behavior_triggers_total",
+		"Dot movements started by behaviors (after a frame without movement), ex. run-away triggers.",
+		"behavior",
+	)
+	metricsRegistry.NewCounterFunc(
+		"laser_cat_servo_moves_total",
+		"Servos position updates.",
+		func() float64 {
+			return float64(servoFieldXY.Moves())
+		},
+	)
+	cameraRestarts := metricsRegistry.NewCounter(
+		"laser_cat_camera_restarts_total",
+		"Camera process restart attempts.",
+	)
+	metricsRegistry.NewCounterFunc(
+		"laser_cat_clips_total",
+		"Motion event clips started.",
+		func() float64 {
+			return float64(eventRecorder.Clips())
+		},
+	)
+
+	// select frame source: recording, remote stream, synthetic room simulation or raspivid
+	var frameSource source.FrameSource
+	var simulatorRoom *simulator.Room
+	cameraEventsCh := make(chan raspivid.Event, 16)
+	switch {
+	case *fSourceFile != "":
+		frameSource = &source.File{
+			Path:  *fSourceFile,
+			FPS:   *fCameraFPS,
+			Speed: *fSourceSpeed,
+			Loop:  *fSourceLoop,
+		}
+	case *fSourceDir != "":
+		frameSource = &source.Dir{
+			Path:  *fSourceDir,
+			FPS:   *fCameraFPS,
+			Speed: *fSourceSpeed,
+			Loop:  *fSourceLoop,
+		}
+	case *fSourceURL != "":
+		frameSource = &source.URL{
+			URL: *fSourceURL,
+		}
+	case *fSimulate:
+		simulatorRoom = &simulator.Room{
+			Width:  cameraWidth,
+			Height: cameraHeight,
+			FPS:    *fCameraFPS,
+		}
+		frameSource = simulatorRoom
+	default:
+		cameraStream, err := newRaspividStream(
+			cameraWidth,
+			cameraHeight,
+			*fCameraFPS,
+			*fCameraFlipH,
+			*fCameraFlipV,
+			*fCameraBackend,
+			*fCameraCommand,
+		)
+		if err != nil {
+			errorAndExit(err)
+		}
+		cameraStream.Events = cameraEventsCh
+		frameSource = cameraStream
+	}
+
+	// cancelled on exit to stop frame source (and terminate camera process)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// start frame stream
+	imageCh, err := frameSource.Start(ctx)
+	if err != nil {
+		errorAndExit(err)
+	}
+
+	// count camera process restarts
+	go func() {
+		defer safety.Guard()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-cameraEventsCh:
+				if event.Type == raspivid.EventRestarting {
+					cameraRestarts.Inc()
+				}
+			}
+		}
+	}()
+
+	// laser emitter, it is turned off on exit and panic
+	laserEmitter := laser.NewLaser(
+		createLaserPin(*fSimulate, *fLaserPin, simulatorRoom),
+		time.Millisecond*time.Duration(*fLaserMaxOnTime),
+		time.Millisecond*time.Duration(*fLaserCoolDown),
+	)
+	defer laserEmitter.Close()
+	defer laserEmitter.Guard()
+	laserEmitter.SetPattern(laserPattern)
+	laserEmitter.On()
+
+	// finds actual laser dot position on images
+	laserDotLocator := &detector.LaserDotLocator{}
+
+	// follows motion blobs across frames
+	motionTracker := &tracker.Tracker{
+		MaxDistance: *fTrackerMaxDistance,
+		Timeout:     time.Millisecond * time.Duration(*fTrackerTimeout),
+	}
+
 	// channel of images with detected motion highlighting and current dot position
 	debugImageCh := make(chan []byte)
 
+	// per frame telemetry for Server-Sent Events clients
+	telemetryHub := telemetry.NewHub()
+
 	var lastState LastState
 
+	// save current laser dot position to draw on debug image
+	go func() {
+		defer safety.Guard()
+		for {
+			var p servo.PercentPoint
+			select {
+			case <-ctx.Done():
+				return
+			case p = <-servoFieldXY.CurrentPercentPointCh:
+			}
+			if simulatorRoom != nil {
+				simulatorRoom.SetDot(p.X, p.Y)
+			}
+			x, y := cameraToLaser.ToImage(p.X, p.Y)
+			lastState.Lock()
+			lastState.CommandedDot = p
+			lastState.CommandedDotPoint = image.Point{
+				X: int(float64(cameraWidth) * x),
+				Y: int(float64(cameraHeight) * y),
+			}
+			lastState.Unlock()
+		}
+	}()
+
+	// set on calibration or HTTP server failure, goroutines set it
+	var exitCode int32
+
+	// closed when frame source is closed or calibration is done
+	frameLoopDoneCh := make(chan struct{})
+
 	// read input jpeg stream, move laser dot and send debug image to output stream
 	go func() {
+		defer close(frameLoopDoneCh)
+		defer safety.Guard()
+
+		// move laser dot over the grid, find it on camera images and save the mapping
+		if *fCalibrate {
+			calibrator := &calibration.Calibrator{
+				Grid:          *fCalibrationGrid,
+				Settle:        time.Millisecond * time.Duration(*fCalibrationSettle),
+				MinConfidence: *fLaserDotMinConfidence,
+				MoveTo:        servoFieldXY.LineTo,
+			}
+			result, err := calibrator.Run(ctx, imageCh)
+			if err == nil {
+				err = result.Save(*fCalibration)
+			}
+			if err != nil {
+				fmt.Println(err)
+				atomic.StoreInt32(&exitCode, 1)
+				return
+			}
+			fmt.Printf("[Main] calibration is saved: %s\n", *fCalibration)
+			return
+		}
+
 		var startTime time.Time
+		var previousMove bool
 		for {
 			startTime = time.Now()
 
-			jpegBytes := <-raspividImageCh
-			img, err := drawer.ImageRGBAFromJpegBytes(jpegBytes)
+			currentFrame, ok := <-imageCh
+			if !ok {
+				fmt.Println("[Main] frame source is closed")
+				return
+			}
+			processingStart := time.Now()
+			framesReceived.Inc()
+			sessionRecorder.WriteRaw(currentFrame.Data)
+
+			img, err := drawer.ImageRGBAFromJpegBytes(currentFrame.Data)
 			if err != nil {
 				fmt.Println(err)
-				return
+				frame.Drops.Drop(frame.StageDecode)
+				continue
 			}
+			framesDecoded.Inc()
 
+			settings.Lock()
 			lastState.Lock()
 
+			// find actual laser dot position, servos may lag behind the commanded position,
+			// a red object is not taken for the dot while the laser is off (hidden, pattern or cool-down)
+			var laserDot detector.LaserDot
+			dotFound := false
+			if laserEmitter.IsOn() {
+				laserDot, dotFound = laserDotLocator.Locate(img)
+			}
+			if dotFound && laserDot.Confidence >= *fLaserDotMinConfidence {
+				lastState.DotPoint = image.Point{X: laserDot.X, Y: laserDot.Y}
+				lastState.DotConfidence = laserDot.Confidence
+			} else {
+				lastState.DotPoint = lastState.CommandedDotPoint
+				lastState.DotConfidence = 0
+			}
+
 			// do not detect laser dot itself
 			detectorBlindSpot := &detector.Rect{
 				X0: lastState.DotPoint.X - *fDetectorBlindSpotRadius,
@@ -210,32 +1115,109 @@ func main() {
 				Y1: lastState.DotPoint.Y + *fDetectorBlindSpotRadius,
 			}
 
-			debugImg, motionPoint := detector.DetectMotion(
-				img,
-				lastState.Img,
-				uint32(*fDetectorThreshold),
-				detectorBlindSpot,
-			)
-			lastState.Img = img
+			detectionStart := time.Now()
+			detection := motionDetector.Detect(img, detectorBlindSpot)
+			detectorDuration.ObserveDuration(time.Since(detectionStart))
+			motionPoint, found := selectTarget(*fDetectorTarget, detection.Blobs, lastState.DotPoint)
 
-			// move laser dot
-			notZeroPoint := motionPoint.X != 0 || motionPoint.Y != 0
-			pointMoved := motionPoint.X != lastState.MotionPoint.X || motionPoint.Y != lastState.MotionPoint.Y
-			if notZeroPoint && pointMoved {
+			// track motion blobs
+			detections := make([]tracker.Detection, len(detection.Blobs))
+			for i, blob := range detection.Blobs {
+				detections[i] = tracker.Detection{
+					X: float64(blob.X) / float64(cameraWidth),
+					Y: float64(blob.Y) / float64(cameraHeight),
+				}
+			}
+			lastState.Tracks = motionTracker.Update(detections, currentFrame.Time)
+
+			// the dot reacts to the selected motion, its track (smoothed, lags behind) gives only velocity
+			var target *tracker.Track
+			if found {
 				lastState.MotionPoint = motionPoint
 
 				motionX := float64(motionPoint.X) / float64(cameraWidth)
 				motionY := float64(motionPoint.Y) / float64(cameraHeight)
+				target = &tracker.Track{X: motionX, Y: motionY}
+				if track, ok := tracker.NearestTrack(lastState.Tracks, motionX, motionY); ok {
+					track.X = motionX
+					track.Y = motionY
+					target = &track
+				}
+			}
+
+			// move laser dot, behaviors work in camera image coordinates
+			dot := servoFieldXY.CurrentPoint()
+			dot.X, dot.Y = cameraToLaser.ToImage(dot.X, dot.Y)
+			if lastState.DotConfidence > 0 {
+				dot.X = float64(lastState.DotPoint.X) / float64(cameraWidth)
+				dot.Y = float64(lastState.DotPoint.Y) / float64(cameraHeight)
+			}
+			decision := behaviors.Decide(behavior.Input{
+				Now:    currentFrame.Time,
+				Tracks: lastState.Tracks,
+				Target: target,
+				Dot:    dot,
+			})
+			if decision.Move {
+				servoFieldXY.LineTo(cameraToLaser.ToServo(decision.Point.X, decision.Point.Y))
+				lastState.Latency = currentFrame.Latency()
+				if !previousMove {
+					behaviorTriggers.WithLabel(behaviors.Name()).Inc()
+				}
+			}
+			previousMove = decision.Move
+			if decision.Hide {
+				laserEmitter.Off()
+			} else {
+				laserEmitter.On()
+			}
 
-				// run away from the motion
-				servoFieldXY.RunAway(motionX, motionY, *fLaserRunAwayRadius, *fFollow)
+			// motion area for event clips
+			motionPixels := 0
+			for _, blob := range detection.Blobs {
+				motionPixels += blob.Pixels
+			}
+			activity := float64(motionPixels) / float64(cameraWidth*cameraHeight)
 
-				//DEBUG: track to the motion
-				//servoFieldXY.LineTo(motionX, motionY)
+			record := telemetryRecord(currentFrame, detection.Blobs, lastState.Tracks, cameraWidth, cameraHeight)
+			record.Behavior = behaviors.Name()
+			record.Move = decision.Move
+			record.CommandedDot = telemetry.Point{X: lastState.CommandedDot.X, Y: lastState.CommandedDot.Y}
+			record.DotConfidence = lastState.DotConfidence
+			record.LaserOn = laserEmitter.IsOn()
+			if found {
+				record.Target = &telemetry.Point{
+					X: float64(motionPoint.X) / float64(cameraWidth),
+					Y: float64(motionPoint.Y) / float64(cameraHeight),
+				}
+			}
+			if lastState.DotConfidence > 0 {
+				record.Dot = &telemetry.Point{
+					X: float64(lastState.DotPoint.X) / float64(cameraWidth),
+					Y: float64(lastState.DotPoint.Y) / float64(cameraHeight),
+				}
 			}
 
 			// draw debug infomation
-			imgDrawer := drawer.New(debugImg)
+			imgDrawer := drawer.New(detection.DebugImg)
+
+			// draw detector region: ignored area is dimmed
+			if detectorRegion != nil {
+				imgDrawer.Dim(func(x, y int) bool {
+					return !detectorRegionMask.At(x, y)
+				})
+				for _, polygon := range detectorRegion.Include {
+					imgDrawer.DrawPolygon(polygonPoints(polygon, cameraWidth, cameraHeight), drawer.ColorWhite)
+				}
+				for _, polygon := range detectorRegion.Exclude {
+					imgDrawer.DrawPolygon(polygonPoints(polygon, cameraWidth, cameraHeight), drawer.ColorOrange)
+				}
+			}
+
+			// draw laser keep-out zones
+			for _, zone := range servoFieldXY.KeepOut() {
+				imgDrawer.DrawPolygon(zonePoints(zone, cameraToLaser, cameraWidth, cameraHeight), drawer.ColorRed)
+			}
 
 			// draw blind spot
 			imgDrawer.DrawRect(
@@ -246,10 +1228,45 @@ func main() {
 				drawer.ColorGreen,
 			)
 
-			// draw current dot position
-			imgDrawer.DrawCrosshead(lastState.DotPoint.X, lastState.DotPoint.Y, params.DetectorBlindSpotRadius, 2)
+			// draw commanded dot position
+			imgDrawer.DrawCrosshead(
+				lastState.CommandedDotPoint.X,
+				lastState.CommandedDotPoint.Y,
+				params.DetectorBlindSpotRadius,
+				2,
+			)
+
+			// draw found laser dot
+			if lastState.DotConfidence > 0 {
+				imgDrawer.DrawRect(
+					laserDot.Rect.X0-1,
+					laserDot.Rect.Y0-1,
+					laserDot.Rect.X1+1,
+					laserDot.Rect.Y1+1,
+					drawer.ColorCyan,
+				)
+			}
+
+			// draw tracks trails
+			for _, track := range lastState.Tracks {
+				trailColor := drawer.Palette[track.ID%len(drawer.Palette)]
+				for i := 1; i < len(track.Trail); i++ {
+					imgDrawer.DrawLine(
+						int(track.Trail[i-1].X*float64(cameraWidth)),
+						int(track.Trail[i-1].Y*float64(cameraHeight)),
+						int(track.Trail[i].X*float64(cameraWidth)),
+						int(track.Trail[i].Y*float64(cameraHeight)),
+						trailColor,
+					)
+				}
+			}
+
+			// draw all detected motion blobs
+			for _, blob := range detection.Blobs {
+				imgDrawer.DrawRect(blob.Rect.X0, blob.Rect.Y0, blob.Rect.X1, blob.Rect.Y1, drawer.ColorPurple)
+			}
 
-			// draw detected motion
+			// draw motion to run away from
 			imgDrawer.DrawRect(
 				lastState.MotionPoint.Rect.X0,
 				lastState.MotionPoint.Rect.Y0,
@@ -258,55 +1275,159 @@ func main() {
 				drawer.ColorRed,
 			)
 
+			latency := lastState.Latency
+			dotConfidence := lastState.DotConfidence
+
 			lastState.Unlock()
+			settings.Unlock()
+
+			frameLatency.ObserveDuration(currentFrame.Latency())
+			record.Latency = telemetry.Milliseconds(currentFrame.Latency())
+			record.ProcessingTime = telemetry.Milliseconds(time.Since(processingStart))
+			record.Dropped = frame.Drops.Dropped()
+			telemetryHub.Publish(record)
+			sessionRecorder.WriteTelemetry(record)
+			eventRecorder.WriteFrame(currentFrame, activity)
+
+			if *fStream || sessionRecorder.Records(recorder.StreamDebug) {
+				encodeStart := time.Now()
+				jpegBytes := imgDrawer.JpegBytes(100)
+				jpegEncodeDuration.ObserveDuration(time.Since(encodeStart))
 
-			if *fStream {
-				debugImageCh <- imgDrawer.JpegBytes(100)
+				sessionRecorder.WriteDebug(jpegBytes)
+
+				if *fStream {
+					select {
+					case debugImageCh <- jpegBytes:
+					case <-ctx.Done():
+					}
+				}
 			}
 
 			if *fDebug {
-				fmt.Printf("fps: %5.1f\tframe took: %s\n", 1/time.Since(startTime).Seconds(), time.Since(startTime))
+				fmt.Printf(
+					"fps: %5.1f\tframe took: %s\tframe: %s#%d\tlatency: %s\tdot confidence: %.2f\tdropped: %v\n",
+					1/time.Since(startTime).Seconds(),
+					time.Since(startTime),
+					currentFrame.Source,
+					currentFrame.Seq,
+					latency,
+					dotConfidence,
+					frame.Drops.Dropped(),
+				)
 			}
 		}
 	}()
 
-	// save current laser dot position to draw on debug image
-	go func() {
-		for {
-			p := <-servoFieldXY.CurrentPercentPointCh
-			lastState.Lock()
-			lastState.DotPoint = image.Point{
-				X: int(float64(cameraWidth) * p.X),
-				Y: int(float64(cameraHeight) * p.Y),
-			}
-			lastState.Unlock()
+	// HTTP server is started only on request, control endpoints change the device state and are opt-in
+	var streamServer *mjpeg.Server
+	if *fStream || *fHTTP {
+		handlers := map[string]http.HandlerFunc{
+			"/events":  telemetryHub.HTTPHandler,
+			"/metrics": metricsRegistry.HTTPHandler,
 		}
-	}()
+		if *fHTTPControl {
+			handlers["/behavior"] = behaviors.HTTPHandler
+			handlers["/settings"] = settings.HTTPHandler
+			handlers["/record"] = sessionRecorder.HTTPHandler
+			handlers["/clips"] = eventRecorder.HTTPHandler
+			handlers["/clips/"] = eventRecorder.HTTPHandler
+		}
+		streamServer = &mjpeg.Server{
+			Addr:      net.JoinHostPort(*fHTTPAddr, *fStreamPort),
+			StreamURL: "/stream",
+			Handlers:  handlers,
+		}
+		if *fStream {
+			streamServer.Source = debugImageCh
+		}
+		metricsRegistry.NewGaugeFunc(
+			"laser_cat_stream_clients",
+			"Connected MJPEG stream clients.",
+			func() float64 {
+				return float64(streamServer.ClientCount())
+			},
+		)
+		metricsRegistry.NewGaugeFunc(
+			"laser_cat_events_clients",
+			"Connected telemetry (Server-Sent Events) clients.",
+			func() float64 {
+				return float64(telemetryHub.ClientCount())
+			},
+		)
 
-	signalCh := make(chan os.Signal, 1)
-	signal.Notify(signalCh, os.Interrupt)
+		// start
+		go func() {
+			defer safety.Guard()
+			err := streamServer.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				fmt.Println(err)
+				atomic.StoreInt32(&exitCode, 1)
+				cancel()
+			}
+		}()
+	}
+
+	signalCh := make(chan os.Signal, 2)
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
 
-	var wg sync.WaitGroup
-	wg.Add(1)
+	// run until interrupted or frame source is closed (ex. end of recording)
+	select {
+	case sig := <-signalCh:
+		fmt.Printf("Interrupted (%s).\n", sig)
+	case <-frameLoopDoneCh:
+	case <-ctx.Done():
+	}
+
+	// second signal skips graceful shutdown
 	go func() {
-		//TODO handle this in all goroutines
+		defer safety.Guard()
 		<-signalCh
-
-		fmt.Println("Interrupted.")
-		wg.Done()
-		os.Exit(0)
+		fmt.Println("Interrupted again, exit.")
+		laserEmitter.Close()
+		os.Exit(1)
 	}()
 
-	if *fStream {
-		streamServer := &mjpeg.Server{
-			Addr:      fmt.Sprintf(":%s", *fStreamPort),
-			StreamURL: "/stream",
-			Source:    debugImageCh,
+	// laser first, so it doesn't draw the way to park position
+	laserEmitter.Close()
+
+	// stop frame source (and terminate camera process) and all frame consumers
+	cancel()
+	select {
+	case <-frameLoopDoneCh:
+	case <-time.After(shutdownTimeout):
+		fmt.Println("[Main] frame loop is not stopped in time")
+	}
+
+	telemetryHub.Close()
+	sessionRecorder.Stop()
+	eventRecorder.Stop()
+
+	if streamServer != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := streamServer.Shutdown(shutdownCtx); err != nil {
+			fmt.Printf("[Main] cannot shutdown stream server, error: %v\n", err)
 		}
+		shutdownCancel()
+	}
 
-		// start
-		streamServer.ListenAndServe()
-	} else {
-		wg.Wait()
+	// random movements would move the dot from park position
+	servoFieldXY.SetRandomMovements(0, 0)
+	if !servoFieldXY.Park(*fParkX, *fParkY, shutdownTimeout) {
+		fmt.Println("[Main] servos are not parked (timeout or no route)")
+	}
+	if err := servoFieldXY.Close(); err != nil {
+		fmt.Printf("[Main] cannot release servos, error: %v\n", err)
+	}
+
+	fmt.Println("[Main] stopped")
+
+	if code := atomic.LoadInt32(&exitCode); code != 0 {
+		// deferred functions do not run on os.Exit
+		if !*fSimulate {
+			rpio.StopPwm()
+			rpio.Close()
+		}
+		os.Exit(int(code))
 	}
 }
@@ -0,0 +1,51 @@
+package behavior
+
+import (
+	"math"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/servo"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/tracker"
+)
+
+const floatEpsilon = 0.001
+
+// Input is what a behavior knows about the world, positions are in percent [0.0-1.0]
+type Input struct {
+	Now    time.Time          // current time
+	Tracks []tracker.Track    // all tracked motion objects
+	Target *tracker.Track     // selected motion: detected position, track velocity (zero if not tracked), nil if no motion
+	Dot    servo.PercentPoint // current laser dot position
+}
+
+// Decision is a behavior output
+type Decision struct {
+	Point servo.PercentPoint // new dot target position
+	Move  bool               // false - keep the current dot target
+	Hide  bool               // turn the laser off, the dot disappears
+}
+
+// Behavior decides where the laser dot goes next
+type Behavior interface {
+	// Name of the behavior to select it by flag
+	Name() string
+
+	// Decide is called on every analyzed frame
+	Decide(in Input) Decision
+}
+
+// moveTo makes a decision to move the dot if the point differs from the current dot position
+func moveTo(dot servo.PercentPoint, x, y float64) Decision {
+	x = math.Max(0, math.Min(1, x))
+	y = math.Max(0, math.Min(1, y))
+
+	return Decision{
+		Point: servo.PercentPoint{X: x, Y: y},
+		Move:  distance(dot.X, dot.Y, x, y) >= floatEpsilon,
+	}
+}
+
+// distance in 4 x 3 space to keep the same scale for both axes
+func distance(x0, y0, x1, y1 float64) float64 {
+	return math.Sqrt(math.Pow((x0-x1)*4, 2)+math.Pow((y0-y1)*3, 2)) / 4
+}
@@ -0,0 +1,146 @@
+package behavior
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/servo"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/tracker"
+)
+
+var now = time.Date(2019, 3, 2, 18, 15, 3, 0, time.UTC)
+
+// testSwitch creates the switch with all behaviors, run-away is active
+func testSwitch() *Switch {
+	return NewSwitch(
+		&RunAway{Radius: 0.2},
+		&Lure{Distance: 0.1},
+		&Wander{Interval: time.Second, Amplitude: 0.05, Margin: 0.05},
+		&Tease{NearDistance: 0.2, DartDistance: 0.5, DartTime: time.Second},
+	)
+}
+
+// testInput is the cat detected at (0.5, 0.5), its smoothed track lags behind
+func testInput(dotX, dotY float64) Input {
+	track := tracker.Track{ID: 1, X: 0.4, Y: 0.5, VX: 0.3}
+	target := track
+	target.X = 0.5
+	target.Y = 0.5
+
+	return Input{
+		Now:    now,
+		Tracks: []tracker.Track{track},
+		Target: &target,
+		Dot:    servo.PercentPoint{X: dotX, Y: dotY},
+	}
+}
+
+func TestSwitchDecide(t *testing.T) {
+	s := testSwitch()
+
+	tests := []struct {
+		name  string
+		dotX  float64
+		check func(d Decision) bool
+	}{
+		{"run-away", 0.55, func(d Decision) bool {
+			return math.Abs(distance(d.Point.X, d.Point.Y, 0.5, 0.5)-0.2) < 1e-9
+		}},
+		{"lure", 0.8, func(d Decision) bool {
+			return math.Abs(d.Point.X-0.6) < 1e-9 && math.Abs(d.Point.Y-0.5) < 1e-9
+		}},
+		{"wander", 0.8, func(d Decision) bool {
+			return distance(d.Point.X, d.Point.Y, 0.8, 0.5) <= 0.1
+		}},
+		{"tease", 0.9, func(d Decision) bool {
+			return math.Abs(distance(d.Point.X, d.Point.Y, 0.5, 0.5)-0.15) < 1e-9 && !d.Hide
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := s.Set(test.name); err != nil {
+				t.Fatal(err)
+			}
+			if s.Name() != test.name {
+				t.Fatalf("active behavior: %s", s.Name())
+			}
+
+			// the dot reacts to the detected point, not to the smoothed track
+			d := s.Decide(testInput(test.dotX, 0.5))
+			if !d.Move || !test.check(d) {
+				t.Errorf("decision: %+v", d)
+			}
+
+			// no motion
+			in := testInput(test.dotX, 0.5)
+			in.Target = nil
+			in.Now = now.Add(time.Hour)
+			if d := s.Decide(in); d.Move && test.name != "wander" {
+				t.Errorf("decision without target: %+v", d)
+			}
+		})
+	}
+}
+
+func TestSwitchSet(t *testing.T) {
+	s := testSwitch()
+
+	if names := s.Names(); !reflect.DeepEqual(names, []string{"run-away", "lure", "wander", "tease"}) {
+		t.Errorf("names: %v", names)
+	}
+	if s.Name() != "run-away" {
+		t.Errorf("the first behavior is not active: %s", s.Name())
+	}
+	if err := s.Set("chase"); err == nil || s.Name() != "run-away" {
+		t.Errorf("unknown behavior is set, error: %v, active: %s", err, s.Name())
+	}
+}
+
+func TestSwitchHTTPHandler(t *testing.T) {
+	s := testSwitch()
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+		active string
+	}{
+		{"get", http.MethodGet, "", http.StatusOK, "run-away"},
+		{"switch", http.MethodPost, `{"active":"tease"}`, http.StatusOK, "tease"},
+		{"unknown behavior", http.MethodPost, `{"active":"chase"}`, http.StatusBadRequest, "tease"},
+		{"invalid JSON", http.MethodPut, `{"active":`, http.StatusBadRequest, "tease"},
+		{"method not allowed", http.MethodDelete, "", http.StatusMethodNotAllowed, "tease"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			s.HTTPHandler(res, httptest.NewRequest(test.method, "/behavior", strings.NewReader(test.body)))
+			if res.Code != test.status {
+				t.Errorf("status: %d, expected: %d", res.Code, test.status)
+			}
+			if s.Name() != test.active {
+				t.Errorf("active behavior: %s, expected: %s", s.Name(), test.active)
+			}
+			if res.Code != http.StatusOK {
+				return
+			}
+
+			var state behaviorState
+			if err := json.NewDecoder(res.Body).Decode(&state); err != nil {
+				t.Fatal(err)
+			}
+			if state.Active != test.active || len(state.Available) != 4 {
+				t.Errorf("state: %+v", state)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package behavior
+
+import (
+	"math"
+	"time"
+)
+
+// Lure lures the target: the dot stays just out of reach in front of it
+type Lure struct {
+	Distance float64       // distance between the target and the dot, as percent of view area width
+	Lead     time.Duration // the dot leads the target by its velocity for this time
+}
+
+// Name of the behavior
+func (b *Lure) Name() string {
+	return "lure"
+}
+
+// Decide where to lure
+func (b *Lure) Decide(in Input) Decision {
+	if in.Target == nil {
+		return Decision{}
+	}
+
+	// direction from the target to the dot (4 x 3 space)
+	dx := (in.Dot.X - in.Target.X) * 4
+	dy := (in.Dot.Y - in.Target.Y) * 3
+	d := math.Sqrt(dx*dx + dy*dy)
+	if d < floatEpsilon {
+		dx, dy, d = 1, 0, 1
+	}
+
+	x := in.Target.X + in.Target.VX*b.Lead.Seconds() + (dx/d)*b.Distance
+	y := in.Target.Y + in.Target.VY*b.Lead.Seconds() + (dy/d)*b.Distance*4/3
+
+	return moveTo(in.Dot, x, y)
+}
@@ -0,0 +1,46 @@
+package behavior
+
+import (
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/servo"
+)
+
+// RunAway keeps the dot away from the target
+type RunAway struct {
+	Radius             float64       // as percent of view area width
+	AlwaysStayOnRadius bool          // run after the target if it's further then the radius
+	Predictive         bool          // run away from the predicted path instead of current position
+	Latency            time.Duration // servo latency to predict the target position
+}
+
+// Name of the behavior
+func (b *RunAway) Name() string {
+	return "run-away"
+}
+
+// Decide where to run
+func (b *RunAway) Decide(in Input) Decision {
+	if in.Target == nil {
+		return Decision{}
+	}
+
+	var x, y float64
+	if b.Predictive {
+		x, y = servo.PredictiveRunAwayPoint(
+			in.Dot.X,
+			in.Dot.Y,
+			in.Target.X,
+			in.Target.Y,
+			in.Target.VX,
+			in.Target.VY,
+			b.Latency,
+			b.Radius,
+			b.AlwaysStayOnRadius,
+		)
+	} else {
+		x, y = servo.RunAwayPoint(in.Dot.X, in.Dot.Y, in.Target.X, in.Target.Y, b.Radius, b.AlwaysStayOnRadius)
+	}
+
+	return moveTo(in.Dot, x, y)
+}
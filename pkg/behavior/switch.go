@@ -0,0 +1,105 @@
+package behavior
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Switch is a behavior that delegates decisions to one of the registered behaviors,
+// the active behavior can be changed at runtime
+type Switch struct {
+	sync.Mutex
+
+	behaviors []Behavior
+	active    Behavior
+}
+
+// Name of the active behavior
+func (s *Switch) Name() string {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.active.Name()
+}
+
+// Decide using the active behavior
+func (s *Switch) Decide(in Input) Decision {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.active.Decide(in)
+}
+
+// Names returns names of all registered behaviors
+func (s *Switch) Names() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	names := make([]string, len(s.behaviors))
+	for i, b := range s.behaviors {
+		names[i] = b.Name()
+	}
+
+	return names
+}
+
+// Set activates a behavior by name
+func (s *Switch) Set(name string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, b := range s.behaviors {
+		if b.Name() == name {
+			if s.active != b {
+				fmt.Printf("[Behavior Switch] switch: %s -> %s\n", s.active.Name(), name)
+			}
+			s.active = b
+			return nil
+		}
+	}
+
+	return fmt.Errorf("[Behavior Switch] unknown behavior '%s'", name)
+}
+
+// behaviorState is JSON representation of the switch state
+type behaviorState struct {
+	Active    string   `json:"active"`
+	Available []string `json:"available,omitempty"`
+}
+
+// HTTPHandler is a handler for HTTP server:
+// GET returns active and available behaviors, POST/PUT with {"active": "<name>"} switches the behavior
+func (s *Switch) HTTPHandler(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		var state behaviorState
+		if err := json.NewDecoder(req.Body).Decode(&state); err != nil {
+			http.Error(res, fmt.Sprintf("cannot parse request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if err := s.Set(state.Active); err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(res, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(res).Encode(behaviorState{
+		Active:    s.Name(),
+		Available: s.Names(),
+	})
+}
+
+// NewSwitch creates new switch, the first behavior is active
+func NewSwitch(behaviors ...Behavior) *Switch {
+	return &Switch{
+		behaviors: behaviors,
+		active:    behaviors[0],
+	}
+}
@@ -0,0 +1,48 @@
+package behavior
+
+import (
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/servo"
+)
+
+// Tease approaches the target slowly and darts away when it gets close
+type Tease struct {
+	NearDistance float64       // distance to the target to start darting, as percent of view area width
+	DartDistance float64       // distance to dart away, as percent of view area width
+	DartTime     time.Duration // time to stay away before approaching again
+	HideTime     time.Duration // the dot disappears when it darts away for this time, 0 - always visible
+
+	dartUntil time.Time
+	hideUntil time.Time
+}
+
+// Name of the behavior
+func (b *Tease) Name() string {
+	return "tease"
+}
+
+// Decide where to approach or dart
+func (b *Tease) Decide(in Input) Decision {
+	hide := in.Now.Before(b.hideUntil)
+	if in.Target == nil || in.Now.Before(b.dartUntil) {
+		return Decision{Hide: hide}
+	}
+
+	// close enough, dart away
+	if distance(in.Dot.X, in.Dot.Y, in.Target.X, in.Target.Y) <= b.NearDistance {
+		b.dartUntil = in.Now.Add(b.DartTime)
+		b.hideUntil = in.Now.Add(b.HideTime)
+		x, y := servo.RunAwayPoint(in.Dot.X, in.Dot.Y, in.Target.X, in.Target.Y, b.DartDistance, true)
+		decision := moveTo(in.Dot, x, y)
+		decision.Hide = b.HideTime > 0
+		return decision
+	}
+
+	// approach to stop just in front of the target
+	x, y := servo.RunAwayPoint(in.Dot.X, in.Dot.Y, in.Target.X, in.Target.Y, b.NearDistance*3/4, true)
+	decision := moveTo(in.Dot, x, y)
+	decision.Hide = hide
+
+	return decision
+}
@@ -0,0 +1,46 @@
+package behavior
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Wander moves the dot to random points nearby, it ignores the targets
+type Wander struct {
+	Interval  time.Duration // time between moves
+	Amplitude float64       // max move distance, as percent of view area width
+	Margin    float64       // distance to keep from the view area edges
+
+	random   *rand.Rand
+	nextMove time.Time
+}
+
+// Name of the behavior
+func (b *Wander) Name() string {
+	return "wander"
+}
+
+// Decide where to wander
+func (b *Wander) Decide(in Input) Decision {
+	if in.Now.Before(b.nextMove) {
+		return Decision{}
+	}
+	b.nextMove = in.Now.Add(b.Interval)
+
+	if b.random == nil {
+		b.random = rand.New(rand.NewSource(in.Now.UnixNano()))
+	}
+
+	x := in.Dot.X + (b.random.Float64()*2-1)*b.Amplitude
+	y := in.Dot.Y + (b.random.Float64()*2-1)*b.Amplitude*4/3
+
+	// bounce off the margins
+	if x < b.Margin || x > 1-b.Margin {
+		x = 2*in.Dot.X - x
+	}
+	if y < b.Margin || y > 1-b.Margin {
+		y = 2*in.Dot.Y - y
+	}
+
+	return moveTo(in.Dot, x, y)
+}
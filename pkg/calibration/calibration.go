@@ -0,0 +1,116 @@
+package calibration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"time"
+)
+
+// Point in percent [0.0-1.0]
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Pair of corresponding points: position in the camera image and servo field position
+type Pair struct {
+	From Point `json:"image"`
+	To   Point `json:"servo"`
+}
+
+// Calibration maps camera image positions to servo field positions and back,
+// all coordinates are in percent [0.0-1.0]
+type Calibration struct {
+	Created      time.Time  `json:"created"`
+	ImageToServo Homography `json:"homography"`
+	Pairs        []Pair     `json:"points,omitempty"` // measured points the homography is fitted to
+	Error        float64    `json:"error"`            // RMS error of measured points in servo percent
+
+	servoToImage Homography
+}
+
+// New creates calibration from measured points
+func New(pairs []Pair) (*Calibration, error) {
+	h, err := Fit(pairs)
+	if err != nil {
+		return nil, fmt.Errorf("[Calibration] cannot fit homography, error: %v", err)
+	}
+
+	c := &Calibration{
+		Created:      time.Now(),
+		ImageToServo: h,
+		Pairs:        pairs,
+	}
+	if err := c.init(); err != nil {
+		return nil, fmt.Errorf("[Calibration] cannot invert homography, error: %v", err)
+	}
+
+	// reprojection error
+	sum := 0.0
+	for _, p := range pairs {
+		x, y := c.ToServo(p.From.X, p.From.Y)
+		sum += math.Pow(x-p.To.X, 2) + math.Pow(y-p.To.Y, 2)
+	}
+	c.Error = math.Sqrt(sum / float64(len(pairs)))
+
+	return c, nil
+}
+
+// Default returns calibration for the camera and laser looking at the same area
+// (servo range matches camera frame)
+func Default() *Calibration {
+	return &Calibration{
+		ImageToServo: Identity(),
+		servoToImage: Identity(),
+	}
+}
+
+func (c *Calibration) init() (err error) {
+	c.servoToImage, err = c.ImageToServo.Inverse()
+	return err
+}
+
+// ToServo converts camera image position to servo field position
+func (c *Calibration) ToServo(x, y float64) (float64, float64) {
+	return c.ImageToServo.Apply(x, y)
+}
+
+// ToImage converts servo field position to camera image position
+func (c *Calibration) ToImage(x, y float64) (float64, float64) {
+	return c.servoToImage.Apply(x, y)
+}
+
+// Save calibration to JSON file
+func (c *Calibration) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("[Calibration] cannot encode calibration, error: %v", err)
+	}
+
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("[Calibration] cannot write file '%s', error: %v", path, err)
+	}
+
+	return nil
+}
+
+// Load calibration from JSON file
+func Load(path string) (*Calibration, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("[Calibration] cannot read file '%s', error: %v", path, err)
+	}
+
+	c := &Calibration{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("[Calibration] cannot parse file '%s', error: %v", path, err)
+	}
+
+	if err := c.init(); err != nil {
+		return nil, fmt.Errorf("[Calibration] invalid homography in file '%s', error: %v", path, err)
+	}
+
+	return c, nil
+}
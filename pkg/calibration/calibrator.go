@@ -0,0 +1,138 @@
+package calibration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/detector"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/drawer"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/frame"
+)
+
+var (
+	defaultGrid    = 4
+	defaultMargin  = 0.1
+	defaultSettle  = time.Second
+	defaultSamples = 3
+)
+
+// Calibrator moves the laser dot over a grid of servo positions, finds the dot on camera images
+// and fits the mapping between image and servo positions
+type Calibrator struct {
+	Grid    int           // number of points per axis
+	Margin  float64       // servo field margin to skip, percent [0.0-0.5)
+	Settle  time.Duration // time to wait for servos to reach the point
+	Samples int           // number of images to average the dot position
+
+	// Locator finds the laser dot on camera images
+	Locator       detector.LaserDotLocator
+	MinConfidence float64 // images with less confident dot are skipped
+
+	// MoveTo moves the laser dot to servo field position
+	MoveTo func(x, y float64)
+}
+
+func (c *Calibrator) setDefaults() {
+	if c.Grid < 2 {
+		c.Grid = defaultGrid
+	}
+	if c.Margin == 0 {
+		c.Margin = defaultMargin
+	}
+	if c.Settle == 0 {
+		c.Settle = defaultSettle
+	}
+	if c.Samples == 0 {
+		c.Samples = defaultSamples
+	}
+}
+
+// Run calibration reading camera images from the channel
+func (c *Calibrator) Run(ctx context.Context, frames chan frame.Frame) (*Calibration, error) {
+	c.setDefaults()
+
+	pairs := []Pair{}
+	step := (1 - 2*c.Margin) / float64(c.Grid-1)
+	for j := 0; j < c.Grid; j++ {
+		for i := 0; i < c.Grid; i++ {
+			servoPoint := Point{
+				X: c.Margin + float64(i)*step,
+				Y: c.Margin + float64(j)*step,
+			}
+
+			imagePoint, found, err := c.measure(ctx, frames, servoPoint)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				fmt.Printf("[Calibration] servo %.2f,%.2f: laser dot is not found\n", servoPoint.X, servoPoint.Y)
+				continue
+			}
+
+			fmt.Printf(
+				"[Calibration] servo %.2f,%.2f: laser dot at %.3f,%.3f\n",
+				servoPoint.X,
+				servoPoint.Y,
+				imagePoint.X,
+				imagePoint.Y,
+			)
+			pairs = append(pairs, Pair{From: imagePoint, To: servoPoint})
+		}
+	}
+
+	calibration, err := New(pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("[Calibration] done: %d points, error: %.4f\n", len(pairs), calibration.Error)
+
+	return calibration, nil
+}
+
+// measure moves the dot to servo position and returns averaged dot position on the following images
+func (c *Calibrator) measure(ctx context.Context, frames chan frame.Frame, servoPoint Point) (Point, bool, error) {
+	c.MoveTo(servoPoint.X, servoPoint.Y)
+	settledTime := time.Now().Add(c.Settle)
+
+	var sum Point
+	found := 0
+	for samples := 0; samples < c.Samples; {
+		var f frame.Frame
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return Point{}, false, ctx.Err()
+		case f, ok = <-frames:
+			if !ok {
+				return Point{}, false, fmt.Errorf("[Calibration] frame source is closed")
+			}
+		}
+
+		// images captured while servos are moving
+		if f.Time.Before(settledTime) {
+			continue
+		}
+		samples++
+
+		img, err := drawer.ImageRGBAFromJpegBytes(f.Data)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		size := img.Bounds().Size()
+		if dot, ok := c.Locator.Locate(img); ok && dot.Confidence >= c.MinConfidence {
+			sum.X += float64(dot.X) / float64(size.X)
+			sum.Y += float64(dot.Y) / float64(size.Y)
+			found++
+		}
+	}
+
+	if found == 0 {
+		return Point{}, false, nil
+	}
+
+	return Point{X: sum.X / float64(found), Y: sum.Y / float64(found)}, true, nil
+}
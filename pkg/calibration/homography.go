@@ -0,0 +1,135 @@
+package calibration
+
+import (
+	"errors"
+	"math"
+)
+
+// ErrDegenerate is returned if points do not define a projective mapping (ex. all on one line)
+var ErrDegenerate = errors.New("degenerate points (less than 4 or on one line)")
+
+// Homography is a 3x3 projective transformation matrix (row-major)
+type Homography [9]float64
+
+// Identity returns homography that does not change points
+func Identity() Homography {
+	return Homography{
+		1, 0, 0,
+		0, 1, 0,
+		0, 0, 1,
+	}
+}
+
+// Apply transforms the point
+func (h Homography) Apply(x, y float64) (float64, float64) {
+	w := h[6]*x + h[7]*y + h[8]
+	if w == 0 {
+		return x, y
+	}
+	return (h[0]*x + h[1]*y + h[2]) / w, (h[3]*x + h[4]*y + h[5]) / w
+}
+
+// Inverse returns homography of the reverse transformation
+func (h Homography) Inverse() (Homography, error) {
+	// adjugate matrix
+	inv := Homography{
+		h[4]*h[8] - h[5]*h[7],
+		h[2]*h[7] - h[1]*h[8],
+		h[1]*h[5] - h[2]*h[4],
+		h[5]*h[6] - h[3]*h[8],
+		h[0]*h[8] - h[2]*h[6],
+		h[2]*h[3] - h[0]*h[5],
+		h[3]*h[7] - h[4]*h[6],
+		h[1]*h[6] - h[0]*h[7],
+		h[0]*h[4] - h[1]*h[3],
+	}
+
+	det := h[0]*inv[0] + h[1]*inv[3] + h[2]*inv[6]
+	if math.Abs(det) < 1e-12 {
+		return Homography{}, ErrDegenerate
+	}
+
+	for i := range inv {
+		inv[i] /= det
+	}
+
+	return inv, nil
+}
+
+// Fit finds homography that maps "from" points to "to" points with the least squares error,
+// at least 4 pairs are required
+func Fit(pairs []Pair) (Homography, error) {
+	if len(pairs) < 4 {
+		return Homography{}, ErrDegenerate
+	}
+
+	// h[8] is fixed to 1, each pair gives two equations for 8 unknowns:
+	// x' = (h0*x + h1*y + h2) / (h6*x + h7*y + 1)
+	// y' = (h3*x + h4*y + h5) / (h6*x + h7*y + 1)
+	// normal equations are accumulated directly: (A^T * A) * h = A^T * b
+	var ata [8][8]float64
+	var atb [8]float64
+	add := func(row [8]float64, b float64) {
+		for i := 0; i < 8; i++ {
+			for j := 0; j < 8; j++ {
+				ata[i][j] += row[i] * row[j]
+			}
+			atb[i] += row[i] * b
+		}
+	}
+	for _, p := range pairs {
+		x, y := p.From.X, p.From.Y
+		u, v := p.To.X, p.To.Y
+		add([8]float64{x, y, 1, 0, 0, 0, -u * x, -u * y}, u)
+		add([8]float64{0, 0, 0, x, y, 1, -v * x, -v * y}, v)
+	}
+
+	solution, ok := solve(ata, atb)
+	if !ok {
+		return Homography{}, ErrDegenerate
+	}
+
+	var h Homography
+	copy(h[:8], solution[:])
+	h[8] = 1
+
+	return h, nil
+}
+
+// solve linear system with Gaussian elimination and partial pivoting
+func solve(a [8][8]float64, b [8]float64) ([8]float64, bool) {
+	const n = 8
+	var x [8]float64
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return x, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+
+		for row := col + 1; row < n; row++ {
+			k := a[row][col] / a[col][col]
+			for j := col; j < n; j++ {
+				a[row][j] -= k * a[col][j]
+			}
+			b[row] -= k * b[col]
+		}
+	}
+
+	for row := n - 1; row >= 0; row-- {
+		sum := b[row]
+		for j := row + 1; j < n; j++ {
+			sum -= a[row][j] * x[j]
+		}
+		x[row] = sum / a[row][row]
+	}
+
+	return x, true
+}
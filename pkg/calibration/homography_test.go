@@ -0,0 +1,120 @@
+package calibration
+
+import (
+	"math"
+	"testing"
+)
+
+const tolerance = 1e-6
+
+// testHomography is a known projective mapping: rotation, scale, shift and perspective
+var testHomography = Homography{
+	0.9, -0.2, 0.1,
+	0.15, 1.1, -0.05,
+	0.3, -0.2, 1,
+}
+
+// testPairs maps the grid of image points with the homography
+func testPairs(h Homography, grid int) []Pair {
+	pairs := []Pair{}
+	for i := 0; i < grid; i++ {
+		for j := 0; j < grid; j++ {
+			x := float64(i) / float64(grid-1)
+			y := float64(j) / float64(grid-1)
+			u, v := h.Apply(x, y)
+			pairs = append(pairs, Pair{From: Point{X: x, Y: y}, To: Point{X: u, Y: v}})
+		}
+	}
+	return pairs
+}
+
+// assertPoint checks the point within tolerance
+func assertPoint(t *testing.T, name string, x, y, expectedX, expectedY float64) {
+	t.Helper()
+	if math.Abs(x-expectedX) > tolerance || math.Abs(y-expectedY) > tolerance {
+		t.Errorf("%s: (%.9f, %.9f), expected: (%.9f, %.9f)", name, x, y, expectedX, expectedY)
+	}
+}
+
+// collinearPairs maps points of the line y = 0.3x + 0.1 with the homography
+func collinearPairs() []Pair {
+	pairs := []Pair{}
+	for x := 0.1; x < 1; x += 0.1 {
+		u, v := testHomography.Apply(x, 0.3*x+0.1)
+		pairs = append(pairs, Pair{From: Point{X: x, Y: 0.3*x + 0.1}, To: Point{X: u, Y: v}})
+	}
+	return pairs
+}
+
+func TestFit(t *testing.T) {
+	for _, grid := range []int{2, 4} {
+		h, err := Fit(testPairs(testHomography, grid))
+		if err != nil {
+			t.Fatalf("grid %d: %v", grid, err)
+		}
+		for i := range h {
+			if math.Abs(h[i]-testHomography[i]) > tolerance {
+				t.Fatalf("grid %d: fitted homography: %v, expected: %v", grid, h, testHomography)
+			}
+		}
+	}
+}
+
+func TestInverse(t *testing.T) {
+	h, err := Fit(testPairs(testHomography, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv, err := h.Inverse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Apply after Inverse is the identity
+	for _, p := range []Point{{0, 0}, {1, 1}, {0.25, 0.8}, {0.6, 0.1}, {-0.2, 1.3}} {
+		u, v := h.Apply(p.X, p.Y)
+		x, y := inv.Apply(u, v)
+		assertPoint(t, "image -> servo -> image", x, y, p.X, p.Y)
+
+		x, y = inv.Apply(p.X, p.Y)
+		u, v = h.Apply(x, y)
+		assertPoint(t, "servo -> image -> servo", u, v, p.X, p.Y)
+	}
+
+	x, y := Identity().Apply(0.3, 0.7)
+	assertPoint(t, "identity", x, y, 0.3, 0.7)
+
+	if _, err := (Homography{}).Inverse(); err != ErrDegenerate {
+		t.Errorf("zero matrix is inverted, error: %v", err)
+	}
+}
+
+func TestFitDegenerate(t *testing.T) {
+	pairs := testPairs(testHomography, 2)
+
+	tests := []struct {
+		name  string
+		pairs []Pair
+	}{
+		{"no points", nil},
+		{"3 points", pairs[:3]},
+		{"collinear points", []Pair{
+			{From: Point{0, 0}, To: Point{0, 0}},
+			{From: Point{0.25, 0.25}, To: Point{0.2, 0.3}},
+			{From: Point{0.5, 0.5}, To: Point{0.4, 0.6}},
+			{From: Point{1, 1}, To: Point{0.8, 1}},
+			{From: Point{0.75, 0.75}, To: Point{0.6, 0.9}},
+		}},
+		{"collinear points with rounding", collinearPairs()},
+		{"duplicate points", []Pair{pairs[0], pairs[1], pairs[2], pairs[1]}},
+		{"the same point", []Pair{pairs[3], pairs[3], pairs[3], pairs[3]}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if h, err := Fit(test.pairs); err != ErrDegenerate {
+				t.Errorf("expected degenerate points error, got: %v, homography: %v", err, h)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package control
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/params"
+)
+
+// Settings are options that can be changed at runtime, values are stored in the flags,
+// hold the lock while reading the flags or using the components they are applied to
+type Settings struct {
+	// FlagSet with the options
+	FlagSet *flag.FlagSet
+
+	// Names of the flags available to read and change
+	Names []string
+
+	// Validate checks all flags after the change, the change is reverted on error
+	Validate func(fs *flag.FlagSet) error
+
+	// Apply is called with changed flag names after successful validation, the lock is held
+	Apply func(changed []string)
+
+	sync.Mutex
+}
+
+func (s *Settings) allowed(name string) bool {
+	for _, n := range s.Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// values returns current values, the lock must be held
+func (s *Settings) values() map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, name := range s.Names {
+		f := s.FlagSet.Lookup(name)
+		if f == nil {
+			continue
+		}
+		if getter, ok := f.Value.(flag.Getter); ok {
+			values[name] = getter.Get()
+		} else {
+			values[name] = f.Value.String()
+		}
+	}
+	return values
+}
+
+// Values returns current values of the settings
+func (s *Settings) Values() map[string]interface{} {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.values()
+}
+
+// Update sets new values, nothing is changed if any of the values is invalid
+func (s *Settings) Update(values map[string]interface{}) error {
+	s.Lock()
+	defer s.Unlock()
+
+	names := []string{}
+	for name := range values {
+		if !s.allowed(name) || s.FlagSet.Lookup(name) == nil {
+			return fmt.Errorf("[Settings] option '%s' cannot be changed at runtime", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	previous := map[string]string{}
+	rollback := func() {
+		for name, value := range previous {
+			s.FlagSet.Set(name, value)
+		}
+	}
+
+	changed := []string{}
+	for _, name := range names {
+		value, err := params.FlagValue(values[name])
+		if err != nil {
+			rollback()
+			return fmt.Errorf("[Settings] option '%s' %v", name, err)
+		}
+
+		f := s.FlagSet.Lookup(name)
+		current := f.Value.String()
+		previous[name] = current // a flag can be changed even if the value is not parsed
+		if err := s.FlagSet.Set(name, value); err != nil {
+			rollback()
+			return fmt.Errorf("[Settings] invalid option '%s' value '%s', error: %v", name, value, err)
+		}
+		if f.Value.String() != current {
+			changed = append(changed, name)
+		}
+	}
+
+	if s.Validate != nil {
+		if err := s.Validate(s.FlagSet); err != nil {
+			rollback()
+			return err
+		}
+	}
+
+	for _, name := range changed {
+		fmt.Printf("[Settings] %s: %s -> %s\n", name, previous[name], s.FlagSet.Lookup(name).Value)
+	}
+	if len(changed) > 0 && s.Apply != nil {
+		s.Apply(changed)
+	}
+
+	return nil
+}
+
+// HTTPHandler is a handler for HTTP server:
+// GET returns current values, POST/PUT with {"<option>": <value>, ...} changes them
+func (s *Settings) HTTPHandler(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		values := map[string]interface{}{}
+		if err := json.NewDecoder(req.Body).Decode(&values); err != nil {
+			http.Error(res, fmt.Sprintf("cannot parse request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if err := s.Update(values); err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(res, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(res).Encode(s.Values())
+}
@@ -0,0 +1,88 @@
+package detector
+
+import (
+	"image"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/drawer"
+)
+
+var (
+	defaultLearningRate = 0.05
+
+	// foreground pixels are learned slower, so a resting cat doesn't vanish immediately
+	foregroundLearningFactor = 0.1
+)
+
+// Background detects motion by subtracting a running-average background model
+// from the image (green channel), so slow moving and resting objects are still detected
+type Background struct {
+	Threshold    uint32     // color difference sensitivity
+	LearningRate float64    // how fast background adapts to changes [0-1], 0 means default
+	BlobFilter   BlobFilter // limits for detected blobs
+	Region       *Mask      // motion is detected only inside the region, nil - the whole image
+
+	width  int
+	height int
+	model  []float64 // background green channel, 16 bit scale
+}
+
+// Detect takes the next image and returns foreground mask
+func (d *Background) Detect(img image.RGBA, blindSpot *Rect) Result {
+	imgDrawer := drawer.New(img)
+	debugImg := imgDrawer.CloneImg()
+
+	w := imgDrawer.Width()
+	h := imgDrawer.Height()
+	mask := NewMask(w, h)
+
+	learningRate := d.LearningRate
+	if learningRate <= 0 {
+		learningRate = defaultLearningRate
+	}
+
+	// (re)initialize the model with the first image
+	initialize := d.model == nil || d.width != w || d.height != h
+	if initialize {
+		d.width = w
+		d.height = h
+		d.model = make([]float64, w*h)
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			g := float64(uint32(img.Pix[img.PixOffset(x, y)+1]) * 0x101) // same scale as color.RGBA()
+
+			if initialize {
+				d.model[i] = g
+				continue
+			}
+
+			// do not learn the blind spot (laser dot) into the background
+			if blindSpot.contains(x, y) {
+				continue
+			}
+
+			// ignored area
+			if !inRegion(d.Region, x, y) {
+				continue
+			}
+
+			rate := learningRate
+			if absUInt32Diff(uint32(g), uint32(d.model[i])) > d.Threshold {
+				debugImg.Set(x, y, drawer.ColorYellow)
+				mask.Set(x, y, true)
+				rate *= foregroundLearningFactor
+			}
+
+			d.model[i] += rate * (g - d.model[i])
+		}
+	}
+
+	return Result{
+		DebugImg:    debugImg,
+		Mask:        mask,
+		MotionPoint: findCenterPoint(mask),
+		Blobs:       FindBlobs(mask, d.BlobFilter),
+	}
+}
@@ -0,0 +1,88 @@
+package detector
+
+import (
+	"image/color"
+	"testing"
+)
+
+var (
+	testThreshold = uint32(7500)
+	testCat       = Rect{X0: 50, Y0: 40, X1: 59, Y1: 49}
+	testCatColor  = color.RGBA{R: 200, G: 200, B: 200, A: 255}
+)
+
+func TestFrameDiff(t *testing.T) {
+	d := &FrameDiff{Threshold: testThreshold}
+	d.Detect(*testRoom(), nil)
+
+	// the cat jumps in
+	img := testRoom()
+	fillRect(img, testCat, testCatColor)
+	result := d.Detect(*img, nil)
+	if result.Mask.Count() != 100 {
+		t.Errorf("motion pixels: %d, expected: 100", result.Mask.Count())
+	}
+	if len(result.Blobs) != 1 || result.Blobs[0].Rect != testCat {
+		t.Errorf("blobs: %+v, expected: %+v", result.Blobs, testCat)
+	}
+
+	// the resting cat vanishes
+	if result := d.Detect(*img, nil); result.Mask.Count() != 0 {
+		t.Errorf("motion pixels of the resting cat: %d", result.Mask.Count())
+	}
+}
+
+func TestBackground(t *testing.T) {
+	d := &Background{Threshold: testThreshold}
+	for i := 0; i < 5; i++ {
+		if result := d.Detect(*testRoom(), nil); result.Mask.Count() != 0 {
+			t.Fatalf("motion pixels in the empty room: %d", result.Mask.Count())
+		}
+	}
+
+	img := testRoom()
+	fillRect(img, testCat, testCatColor)
+
+	// the resting cat is still detected, foreground is learned slowly
+	for i := 0; i < 30; i++ {
+		result := d.Detect(*img, nil)
+		if result.Mask.Count() != 100 || len(result.Blobs) != 1 || result.Blobs[0].Rect != testCat {
+			t.Fatalf("frame %d: motion pixels: %d, blobs: %+v", i, result.Mask.Count(), result.Blobs)
+		}
+	}
+
+	// the cat sleeps there, it becomes a part of the background
+	for i := 0; i < 400; i++ {
+		d.Detect(*img, nil)
+	}
+	if result := d.Detect(*img, nil); result.Mask.Count() != 0 {
+		t.Errorf("motion pixels of the learned background: %d", result.Mask.Count())
+	}
+}
+
+func TestBackgroundBlindSpotAndRegion(t *testing.T) {
+	region := NewMask(128, 96)
+	for y := 0; y < 96; y++ {
+		for x := 0; x < 64; x++ {
+			region.Set(x, y, true)
+		}
+	}
+	d := &Background{Threshold: testThreshold, Region: region}
+	d.Detect(*testRoom(), nil)
+
+	// the laser dot and the cat outside the region
+	img := testRoom()
+	dot := Rect{X0: 20, Y0: 20, X1: 22, Y1: 22}
+	fillRect(img, dot, testCatColor)
+	fillRect(img, Rect{X0: 80, Y0: 40, X1: 89, Y1: 49}, testCatColor)
+
+	blindSpot := &Rect{X0: 16, Y0: 16, X1: 26, Y1: 26}
+	if result := d.Detect(*img, blindSpot); result.Mask.Count() != 0 {
+		t.Errorf("motion pixels: %d, blobs: %+v", result.Mask.Count(), result.Blobs)
+	}
+
+	// the blind spot is not learned into the background
+	if result := d.Detect(*img, nil); result.Mask.Count() != 9 {
+		t.Errorf("motion pixels of the dot: %d, expected: 9", result.Mask.Count())
+	}
+}
@@ -0,0 +1,155 @@
+package detector
+
+import (
+	"sort"
+)
+
+// Blob is a connected component of detected motion pixels
+type Blob struct {
+	X      int  // centroid X
+	Y      int  // centroid Y
+	Rect   Rect // bounding box
+	Area   int  // bounding box area
+	Pixels int  // number of motion pixels
+}
+
+// Point returns blob centroid as a motion point
+func (b Blob) Point() Point {
+	return Point{
+		X:    b.X,
+		Y:    b.Y,
+		Rect: b.Rect,
+	}
+}
+
+// AspectRatio returns bounding box width to height ratio
+func (b Blob) AspectRatio() float64 {
+	return float64(b.Rect.X1-b.Rect.X0+1) / float64(b.Rect.Y1-b.Rect.Y0+1)
+}
+
+// BlobFilter sets limits for blobs, zero value disables a limit
+type BlobFilter struct {
+	MinArea        int     // min bounding box area
+	MaxArea        int     // max bounding box area
+	MinAspectRatio float64 // min width to height ratio
+	MaxAspectRatio float64 // max width to height ratio
+}
+
+// Match checks blob matches the filter
+func (f BlobFilter) Match(b Blob) bool {
+	if f.MinArea > 0 && b.Area < f.MinArea {
+		return false
+	}
+	if f.MaxArea > 0 && b.Area > f.MaxArea {
+		return false
+	}
+	if f.MinAspectRatio > 0 && b.AspectRatio() < f.MinAspectRatio {
+		return false
+	}
+	if f.MaxAspectRatio > 0 && b.AspectRatio() > f.MaxAspectRatio {
+		return false
+	}
+	return true
+}
+
+// FindBlobs labels 8-connected components of the mask,
+// returns blobs matching the filter sorted by number of pixels (the largest first)
+func FindBlobs(mask *Mask, filter BlobFilter) []Blob {
+	w := mask.Width
+	h := mask.Height
+
+	visited := make([]bool, w*h)
+	stack := []int{}
+	blobs := []Blob{}
+
+	for start, v := range mask.Pix {
+		if v == 0 || visited[start] {
+			continue
+		}
+
+		// flood fill the component
+		rect := Rect{X0: w, Y0: h, X1: -1, Y1: -1}
+		sumX, sumY, pixels := 0, 0, 0
+
+		visited[start] = true
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			x := i % w
+			y := i / w
+
+			pixels++
+			sumX += x
+			sumY += y
+			if x < rect.X0 {
+				rect.X0 = x
+			}
+			if x > rect.X1 {
+				rect.X1 = x
+			}
+			if y < rect.Y0 {
+				rect.Y0 = y
+			}
+			if y > rect.Y1 {
+				rect.Y1 = y
+			}
+
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx := x + dx
+					ny := y + dy
+					if nx < 0 || ny < 0 || nx >= w || ny >= h {
+						continue
+					}
+					n := ny*w + nx
+					if mask.Pix[n] != 0 && !visited[n] {
+						visited[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+
+		blob := Blob{
+			X:      sumX / pixels,
+			Y:      sumY / pixels,
+			Rect:   rect,
+			Area:   (rect.X1 - rect.X0 + 1) * (rect.Y1 - rect.Y0 + 1),
+			Pixels: pixels,
+		}
+		if filter.Match(blob) {
+			blobs = append(blobs, blob)
+		}
+	}
+
+	sort.SliceStable(blobs, func(i, j int) bool {
+		return blobs[i].Pixels > blobs[j].Pixels
+	})
+
+	return blobs
+}
+
+// LargestBlob returns the blob with the most pixels
+func LargestBlob(blobs []Blob) (blob Blob, ok bool) {
+	for i, b := range blobs {
+		if i == 0 || b.Pixels > blob.Pixels {
+			blob = b
+		}
+	}
+	return blob, len(blobs) > 0
+}
+
+// NearestBlob returns the blob with the closest centroid to the point
+func NearestBlob(blobs []Blob, x, y int) (blob Blob, ok bool) {
+	minDistance := -1
+	for _, b := range blobs {
+		d := (b.X-x)*(b.X-x) + (b.Y-y)*(b.Y-y)
+		if minDistance == -1 || d < minDistance {
+			minDistance = d
+			blob = b
+		}
+	}
+	return blob, len(blobs) > 0
+}
@@ -0,0 +1,100 @@
+package detector
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testMask builds a mask from rows, "#" - set pixel
+func testMask(rows ...string) *Mask {
+	mask := NewMask(len(rows[0]), len(rows))
+	for y, row := range rows {
+		for x, c := range row {
+			mask.Set(x, y, c == '#')
+		}
+	}
+	return mask
+}
+
+func TestFindBlobs(t *testing.T) {
+	mask := testMask(
+		"##........",
+		"##........",
+		"..#.......", // diagonal neighbour of the square
+		"......###.",
+		"......###.",
+		"......###.",
+		"#.........",
+	)
+
+	blobs := FindBlobs(mask, BlobFilter{})
+	expected := []Blob{
+		{X: 7, Y: 4, Rect: Rect{X0: 6, Y0: 3, X1: 8, Y1: 5}, Area: 9, Pixels: 9},
+		{X: 0, Y: 0, Rect: Rect{X0: 0, Y0: 0, X1: 2, Y1: 2}, Area: 9, Pixels: 5},
+		{X: 0, Y: 6, Rect: Rect{X0: 0, Y0: 6, X1: 0, Y1: 6}, Area: 1, Pixels: 1},
+	}
+	if !reflect.DeepEqual(blobs, expected) {
+		t.Errorf("blobs:\n%+v\nexpected:\n%+v", blobs, expected)
+	}
+
+	if blob, ok := LargestBlob(blobs); !ok || blob != expected[0] {
+		t.Errorf("largest blob: %+v", blob)
+	}
+	if blob, ok := NearestBlob(blobs, 1, 5); !ok || blob != expected[2] {
+		t.Errorf("nearest blob: %+v", blob)
+	}
+	if _, ok := LargestBlob(FindBlobs(NewMask(10, 10), BlobFilter{})); ok {
+		t.Error("blob is found on empty mask")
+	}
+}
+
+func TestBlobFilter(t *testing.T) {
+	mask := testMask(
+		"#.........",
+		"..........",
+		"..####..#.",
+		"..####..#.",
+		"........#.",
+		"........#.",
+	)
+
+	tests := []struct {
+		name   string
+		filter BlobFilter
+		blobs  []int // pixels of expected blobs
+	}{
+		{"no limits", BlobFilter{}, []int{8, 4, 1}},
+		{"min area", BlobFilter{MinArea: 4}, []int{8, 4}},
+		{"max area", BlobFilter{MaxArea: 4}, []int{4, 1}},
+		{"area range", BlobFilter{MinArea: 2, MaxArea: 4}, []int{4}},
+		{"wide", BlobFilter{MinAspectRatio: 1.5}, []int{8}},
+		{"tall", BlobFilter{MaxAspectRatio: 0.5}, []int{4}},
+		{"square-ish", BlobFilter{MinAspectRatio: 0.5, MaxAspectRatio: 1.5}, []int{1}},
+		{"nothing matches", BlobFilter{MinArea: 100}, []int{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pixels := []int{}
+			for _, blob := range FindBlobs(mask, test.filter) {
+				pixels = append(pixels, blob.Pixels)
+			}
+			if !reflect.DeepEqual(pixels, test.blobs) {
+				t.Errorf("blob pixels: %v, expected: %v", pixels, test.blobs)
+			}
+		})
+	}
+}
+
+func TestMask(t *testing.T) {
+	mask := testMask("#.#", ".#.")
+	if mask.Count() != 3 || !mask.At(2, 0) || mask.At(1, 0) {
+		t.Errorf("mask: %v", mask.Pix)
+	}
+
+	// pixels outside the mask are ignored
+	mask.Set(-1, 5, true)
+	if mask.At(-1, 5) || mask.Count() != 3 {
+		t.Error("pixel outside the mask is set")
+	}
+}
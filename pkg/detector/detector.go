@@ -23,12 +23,67 @@ type Point struct {
 	Rect Rect
 }
 
+// contains checks the point is strictly inside the rectangle, nil rectangle contains nothing
+func (r *Rect) contains(x, y int) bool {
+	return r != nil && r.X0 < x && x < r.X1 && r.Y0 < y && y < r.Y1
+}
+
+// Result of motion detection
+type Result struct {
+	DebugImg    image.RGBA // image with highlighted motion
+	Mask        *Mask      // detected motion (foreground) pixels
+	MotionPoint Point      // center of all detected motion
+	Blobs       []Blob     // connected components of detected motion, the largest first
+}
+
+// Detector finds motion on a sequence of images
+type Detector interface {
+	// Detect takes the next image and returns detected motion,
+	// pixels inside the blind spot are ignored
+	Detect(img image.RGBA, blindSpot *Rect) Result
+}
+
+// FrameDiff detects motion as a difference between the image and the previous one
+type FrameDiff struct {
+	Threshold  uint32     // color difference sensitivity
+	BlobFilter BlobFilter // limits for detected blobs
+	Region     *Mask      // motion is detected only inside the region, nil - the whole image
+
+	previousImg image.RGBA
+}
+
+// Detect takes the next image and returns detected motion
+func (d *FrameDiff) Detect(img image.RGBA, blindSpot *Rect) Result {
+	debugImg, mask := diffMask(img, d.previousImg, d.Threshold, blindSpot, d.Region)
+	d.previousImg = img
+
+	return Result{
+		DebugImg:    debugImg,
+		Mask:        mask,
+		MotionPoint: findCenterPoint(mask),
+		Blobs:       FindBlobs(mask, d.BlobFilter),
+	}
+}
+
 // DetectMotion takes a channel with image.RGBA stream and
 // returns a channel of XY Points of detected motion
 func DetectMotion(img, previousImg image.RGBA, threshold uint32, blindSpot *Rect) (
 	debugImg image.RGBA,
 	motionPoint Point,
 ) {
+	debugImg, mask := diffMask(img, previousImg, threshold, blindSpot, nil)
+	motionPoint = findCenterPoint(mask)
+
+	return
+}
+
+// diffMask calculates difference between images based on green channel
+func diffMask(
+	img, previousImg image.RGBA,
+	threshold uint32,
+	blindSpot *Rect,
+	region *Mask,
+) (debugImg image.RGBA, mask *Mask) {
 	imgDrawer := drawer.New(img)
 	debugImg = imgDrawer.CloneImg()
 
@@ -36,33 +91,27 @@ func DetectMotion(img, previousImg image.RGBA, threshold uint32, blindSpot *Rect
 	w := imgDrawer.Width()
 	h := imgDrawer.Height()
 
-	//TODO use struct with one array underlines slices:
-	// https://golang.org/doc/effective_go.html#two_dimensional_slices
-	diffArray := make([][]int, w)
-	for i := range diffArray {
-		diffArray[i] = make([]int, h)
-	}
+	mask = NewMask(w, h)
 
-	// calculate difference between images based on green channel
 	for x := 0; x < w; x++ {
 		for y := 0; y < h; y++ {
-			//TODO what color channel to use? Detector still can be self-triggers by laser dot
+			//TODO what color channel to use? Laser dot is masked out by blind spot around its found position
 			_, g1, _, _ := debugImg.At(x, y).RGBA()
 			_, g2, _, _ := previousImg.At(x, y).RGBA()
 			gDiff := absUInt32Diff(g1, g2)
-			insideBlindSpot := blindSpot.X0 < x && x < blindSpot.X1 && blindSpot.Y0 < y && y < blindSpot.Y1
-			if gDiff > threshold && !insideBlindSpot {
+			if gDiff > threshold && !blindSpot.contains(x, y) && inRegion(region, x, y) {
 				debugImg.Set(x, y, drawer.ColorYellow)
-				diffArray[x][y] = 1
-				// } else {
-				// 	d.img.Set(x, y, &color.RGBA{0, uint8(g2), 0, 255})
+				mask.Set(x, y, true)
 			}
 		}
 	}
 
-	motionPoint = findCenterPoint(diffArray)
+	return debugImg, mask
+}
 
-	return
+// inRegion checks the pixel is inside the region, nil region is the whole image
+func inRegion(region *Mask, x, y int) bool {
+	return region == nil || region.At(x, y)
 }
 
 func absUInt32Diff(a, b uint32) uint32 {
@@ -73,12 +122,12 @@ func absUInt32Diff(a, b uint32) uint32 {
 }
 
 //findCenterPoint of binary presented shape
-func findCenterPoint(a [][]int) Point {
+func findCenterPoint(mask *Mask) Point {
 	var x0, y0, x1, y1 int
 
-	for x, row := range a {
-		for y := range row {
-			if a[x][y] == 1 {
+	for x := 0; x < mask.Width; x++ {
+		for y := 0; y < mask.Height; y++ {
+			if mask.At(x, y) {
 				if x0 == 0 {
 					x0 = x
 				}
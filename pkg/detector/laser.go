@@ -0,0 +1,94 @@
+package detector
+
+import (
+	"image"
+	"math"
+)
+
+var (
+	defaultLaserMinScore = 60 // red dominance: red minus max of green and blue
+	defaultLaserMaxArea  = 64 // bounding box area in pixels
+)
+
+// LaserDot is the laser dot found on the image
+type LaserDot struct {
+	X          int     // center X
+	Y          int     // center Y
+	Rect       Rect    // bounding box
+	Confidence float64 // [0.0-1.0]
+}
+
+// LaserDotLocator finds the red laser dot on images
+type LaserDotLocator struct {
+	MinScore int // min red dominance (red minus max of green and blue) of the dot center
+	MaxArea  int // expected max dot bounding box area, bigger spots get lower confidence
+}
+
+func (l *LaserDotLocator) setDefaults() {
+	if l.MinScore == 0 {
+		l.MinScore = defaultLaserMinScore
+	}
+	if l.MaxArea == 0 {
+		l.MaxArea = defaultLaserMaxArea
+	}
+}
+
+// redScore returns red dominance of the pixel
+func redScore(img *image.RGBA, x, y int) int {
+	i := img.PixOffset(x, y)
+	r, g, b := int(img.Pix[i]), int(img.Pix[i+1]), int(img.Pix[i+2])
+	if g > b {
+		return r - g
+	}
+	return r - b
+}
+
+// Locate returns the reddest compact spot on the image,
+// confidence is lower for dim, big or several red spots
+func (l *LaserDotLocator) Locate(img image.RGBA) (dot LaserDot, found bool) {
+	l.setDefaults()
+
+	bounds := img.Bounds()
+	w := bounds.Dx()
+	h := bounds.Dy()
+
+	maxScore := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if s := redScore(&img, bounds.Min.X+x, bounds.Min.Y+y); s > maxScore {
+				maxScore = s
+			}
+		}
+	}
+	if maxScore < l.MinScore {
+		return dot, false
+	}
+
+	// pixels close to the peak
+	mask := NewMask(w, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if redScore(&img, bounds.Min.X+x, bounds.Min.Y+y) >= maxScore*3/4 {
+				mask.Set(x, y, true)
+			}
+		}
+	}
+
+	blob, ok := LargestBlob(FindBlobs(mask, BlobFilter{}))
+	if !ok {
+		return dot, false
+	}
+
+	confidence := float64(maxScore) / 255
+	if blob.Area > l.MaxArea {
+		confidence *= float64(l.MaxArea) / float64(blob.Area)
+	}
+	confidence *= float64(blob.Pixels) / float64(mask.Count())
+
+	return LaserDot{
+		X:          blob.X,
+		Y:          blob.Y,
+		Rect:       blob.Rect,
+		Confidence: math.Min(1, confidence),
+	}, true
+}
@@ -0,0 +1,72 @@
+package detector
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testRoom returns a gray image of the camera size
+func testRoom() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 128, 96))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 100, 100, 100, 255
+	}
+	return img
+}
+
+// fillRect fills the rectangle (inclusive) with the color
+func fillRect(img *image.RGBA, r Rect, c color.RGBA) {
+	for y := r.Y0; y <= r.Y1; y++ {
+		for x := r.X0; x <= r.X1; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func TestLaserDotLocator(t *testing.T) {
+	red := color.RGBA{R: 255, G: 40, B: 40, A: 255}
+	minConfidence := 0.5
+
+	tests := []struct {
+		name     string
+		spots    []Rect
+		color    color.RGBA
+		found    bool // a spot is found
+		accepted bool // the spot confidence is enough to use it as the dot
+		x, y     int
+	}{
+		{"dot", []Rect{{X0: 39, Y0: 29, X1: 41, Y1: 31}}, red, true, true, 40, 30},
+		{"big red object", []Rect{{X0: 10, Y0: 10, X1: 39, Y1: 29}}, red, true, false, 0, 0},
+		{"two dots", []Rect{{X0: 9, Y0: 9, X1: 11, Y1: 11}, {X0: 99, Y0: 79, X1: 101, Y1: 81}}, red, true, false, 0, 0},
+		{"dim red", []Rect{{X0: 39, Y0: 29, X1: 41, Y1: 31}}, color.RGBA{R: 140, G: 100, B: 100, A: 255}, false, false, 0, 0},
+		{"no red pixels", nil, red, false, false, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img := testRoom()
+			for _, spot := range test.spots {
+				fillRect(img, spot, test.color)
+			}
+
+			l := &LaserDotLocator{}
+			dot, found := l.Locate(*img)
+			if found != test.found {
+				t.Fatalf("found: %v, expected: %v (dot: %+v)", found, test.found, dot)
+			}
+			if !found {
+				return
+			}
+			if accepted := dot.Confidence >= minConfidence; accepted != test.accepted {
+				t.Errorf("confidence: %.2f, expected to be accepted: %v", dot.Confidence, test.accepted)
+			}
+			if test.accepted && (dot.X != test.x || dot.Y != test.y) {
+				t.Errorf("dot: (%d, %d), expected: (%d, %d)", dot.X, dot.Y, test.x, test.y)
+			}
+			if dot.Confidence < 0 || dot.Confidence > 1 {
+				t.Errorf("confidence out of range: %.2f", dot.Confidence)
+			}
+		})
+	}
+}
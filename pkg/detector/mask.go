@@ -0,0 +1,48 @@
+package detector
+
+// Mask is a binary image, it is used for detected motion (foreground) pixels
+type Mask struct {
+	Width  int
+	Height int
+	Pix    []uint8 // 1 - set, 0 - not set, row by row
+}
+
+// At returns true if the pixel is set
+func (m *Mask) At(x, y int) bool {
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return false
+	}
+	return m.Pix[y*m.Width+x] != 0
+}
+
+// Set sets or clears the pixel
+func (m *Mask) Set(x, y int, value bool) {
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return
+	}
+	if value {
+		m.Pix[y*m.Width+x] = 1
+	} else {
+		m.Pix[y*m.Width+x] = 0
+	}
+}
+
+// Count returns number of set pixels
+func (m *Mask) Count() int {
+	count := 0
+	for _, v := range m.Pix {
+		if v != 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// NewMask creates new empty mask
+func NewMask(width, height int) *Mask {
+	return &Mask{
+		Width:  width,
+		Height: height,
+		Pix:    make([]uint8, width*height),
+	}
+}
@@ -0,0 +1,127 @@
+package detector
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // bitmap mask formats
+	_ "image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// PolygonPoint is a polygon vertex in percent of the image [0.0-1.0]
+type PolygonPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Polygon is a closed shape in percent of the image
+type Polygon []PolygonPoint
+
+// Contains checks the point is inside the polygon (even-odd rule)
+func (p Polygon) Contains(x, y float64) bool {
+	inside := false
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		if (p[i].Y > y) != (p[j].Y > y) &&
+			x < (p[j].X-p[i].X)*(y-p[i].Y)/(p[j].Y-p[i].Y)+p[i].X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Region is an area of the image where motion is detected, it is a combination of
+// include polygons (the whole image if empty), exclude polygons and optional bitmap mask
+type Region struct {
+	Include []Polygon `json:"include,omitempty"`
+	Exclude []Polygon `json:"exclude,omitempty"`
+
+	// path to bitmap mask (PNG or JPEG, scaled to the image size):
+	// bright pixels - detect motion, dark pixels - ignore, relative to the region file
+	Bitmap string `json:"bitmap,omitempty"`
+
+	bitmap image.Image
+}
+
+// Mask rasterizes the region for the image size, set pixels are inside the region
+func (r *Region) Mask(width, height int) *Mask {
+	mask := NewMask(width, height)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// pixel center in percent
+			px := (float64(x) + 0.5) / float64(width)
+			py := (float64(y) + 0.5) / float64(height)
+
+			inside := len(r.Include) == 0
+			for _, p := range r.Include {
+				if p.Contains(px, py) {
+					inside = true
+					break
+				}
+			}
+			for _, p := range r.Exclude {
+				if inside && p.Contains(px, py) {
+					inside = false
+				}
+			}
+			if inside && r.bitmap != nil {
+				b := r.bitmap.Bounds()
+				c := color.GrayModel.Convert(r.bitmap.At(
+					b.Min.X+int(px*float64(b.Dx())),
+					b.Min.Y+int(py*float64(b.Dy())),
+				)).(color.Gray)
+				inside = c.Y >= 128
+			}
+
+			mask.Set(x, y, inside)
+		}
+	}
+
+	return mask
+}
+
+// LoadRegion reads region from JSON file:
+// {"include": [[{"x": 0.1, "y": 0.1}, ...]], "exclude": [...], "bitmap": "mask.png"}
+func LoadRegion(path string) (*Region, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("[Detector] cannot read region file '%s', error: %v", path, err)
+	}
+
+	r := &Region{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("[Detector] cannot parse region file '%s', error: %v", path, err)
+	}
+
+	for _, polygons := range [][]Polygon{r.Include, r.Exclude} {
+		for _, p := range polygons {
+			if len(p) < 3 {
+				return nil, fmt.Errorf("[Detector] polygon must have at least 3 points in region file '%s'", path)
+			}
+		}
+	}
+
+	if r.Bitmap != "" {
+		bitmapPath := r.Bitmap
+		if !filepath.IsAbs(bitmapPath) {
+			bitmapPath = filepath.Join(filepath.Dir(path), bitmapPath)
+		}
+
+		f, err := os.Open(bitmapPath)
+		if err != nil {
+			return nil, fmt.Errorf("[Detector] cannot open bitmap mask '%s', error: %v", bitmapPath, err)
+		}
+		defer f.Close()
+
+		r.bitmap, _, err = image.Decode(f)
+		if err != nil {
+			return nil, fmt.Errorf("[Detector] cannot decode bitmap mask '%s', error: %v", bitmapPath, err)
+		}
+	}
+
+	return r, nil
+}
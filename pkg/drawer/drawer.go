@@ -15,8 +15,15 @@ var (
 	ColorGreen  = color.RGBA{0, 255, 0, 255}
 	ColorBlue   = color.RGBA{0, 0, 255, 255}
 	ColorRed    = color.RGBA{255, 0, 0, 255}
+	ColorPurple = color.RGBA{255, 0, 255, 255}
+	ColorCyan   = color.RGBA{0, 255, 255, 255}
+	ColorOrange = color.RGBA{255, 128, 0, 255}
+	ColorWhite  = color.RGBA{255, 255, 255, 255}
 )
 
+// Palette of distinguishable colors to draw several objects (ex. by ID)
+var Palette = []color.RGBA{ColorCyan, ColorOrange, ColorPurple, ColorWhite, ColorGreen, ColorBlue}
+
 // Drawer to draw shapes on image
 type Drawer struct {
 	img image.RGBA
@@ -83,6 +90,72 @@ func (d *Drawer) DrawRect(x0, y0, x1, y1 int, c color.RGBA) {
 	}
 }
 
+// DrawLine on image
+func (d *Drawer) DrawLine(x0, y0, x1, y1 int, c color.RGBA) {
+	imgSize := d.img.Bounds().Size()
+
+	dx := x1 - x0
+	if dx < 0 {
+		dx = -dx
+	}
+	dy := y1 - y0
+	if dy > 0 {
+		dy = -dy
+	}
+	sx := 1
+	if x0 > x1 {
+		sx = -1
+	}
+	sy := 1
+	if y0 > y1 {
+		sy = -1
+	}
+
+	// Bresenham's line algorithm
+	e := dx + dy
+	for {
+		if x0 >= 0 && x0 < imgSize.X && y0 >= 0 && y0 < imgSize.Y {
+			d.img.Set(x0, y0, c)
+		}
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// DrawPolygon on image, the last point is connected to the first one
+func (d *Drawer) DrawPolygon(points []image.Point, c color.RGBA) {
+	for i := range points {
+		next := points[(i+1)%len(points)]
+		d.DrawLine(points[i].X, points[i].Y, next.X, next.Y, c)
+	}
+}
+
+// Dim darkens pixels the function returns true for
+func (d *Drawer) Dim(dimmed func(x, y int) bool) {
+	size := d.img.Bounds().Size()
+
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			if dimmed(x, y) {
+				i := d.img.PixOffset(x, y)
+				d.img.Pix[i] /= 3
+				d.img.Pix[i+1] /= 3
+				d.img.Pix[i+2] /= 3
+			}
+		}
+	}
+}
+
 // Clone current drawer
 func (d *Drawer) Clone() Drawer {
 	return Drawer{img: d.CloneImg()}
@@ -0,0 +1,92 @@
+package frame
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Frame is a JPEG image with capture metadata
+type Frame struct {
+	Seq      uint64    // sequence number within the source, starts from 1
+	Time     time.Time // capture time, recorded time of replayed images
+	Received time.Time // when the image was read from the source
+	Source   string    // source ID, ex. "raspivid" or "file:session.mjpeg"
+	Data     []byte    // JPEG image bytes
+}
+
+// Size returns image size in bytes
+func (f Frame) Size() int {
+	return len(f.Data)
+}
+
+// Latency returns time passed since the image was read from the source
+func (f Frame) Latency() time.Duration {
+	return time.Since(f.Received)
+}
+
+// Sequence stamps images of a single source with sequence numbers and capture time
+type Sequence struct {
+	Source string
+
+	seq uint64
+}
+
+// Next creates new frame from JPEG image bytes captured now
+func (s *Sequence) Next(data []byte) Frame {
+	return s.NextAt(data, time.Now())
+}
+
+// NextAt creates new frame from JPEG image bytes captured at the time (ex. replayed recording)
+func (s *Sequence) NextAt(data []byte, captured time.Time) Frame {
+	return Frame{
+		Seq:      atomic.AddUint64(&s.seq, 1),
+		Time:     captured,
+		Received: time.Now(),
+		Source:   s.Source,
+		Data:     data,
+	}
+}
+
+// pipeline stages where frames can be dropped
+const (
+	StageCapture = "capture" // source is faster than the main loop
+	StageCorrupt = "corrupt" // corrupted JPEG in MJPEG stream
+	StageDecode  = "decode"  // JPEG image cannot be decoded
+	StageStream  = "stream"  // MJPEG stream client is too slow
+	StageRecord  = "record"  // recording disk is too slow
+	StageClip    = "clip"    // event clips disk is too slow
+)
+
+// Counters counts dropped frames by pipeline stage
+type Counters struct {
+	sync.Mutex
+	dropped map[string]uint64
+}
+
+// Drop increases the stage counter
+func (c *Counters) Drop(stage string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.dropped == nil {
+		c.dropped = map[string]uint64{}
+	}
+	c.dropped[stage]++
+}
+
+// Dropped returns a copy of counters
+func (c *Counters) Dropped() map[string]uint64 {
+	c.Lock()
+	defer c.Unlock()
+
+	dropped := make(map[string]uint64, len(c.dropped))
+	for stage, count := range c.dropped {
+		dropped[stage] = count
+	}
+
+	return dropped
+}
+
+// Drops counts frames dropped in the application pipeline
+var Drops = &Counters{}
@@ -0,0 +1,215 @@
+package laser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/safety"
+)
+
+var (
+	tickInterval    = 10 * time.Millisecond
+	defaultCoolDown = time.Second
+)
+
+// Pattern is a sequence of on and off durations: on, off, on, off...; nil pattern is steady light
+type Pattern []time.Duration
+
+// Patterns available by name
+var Patterns = map[string]Pattern{
+	"steady":    nil,
+	"blink":     {250 * time.Millisecond, 250 * time.Millisecond},
+	"flicker":   {40 * time.Millisecond, 60 * time.Millisecond},
+	"heartbeat": {100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond, 700 * time.Millisecond},
+}
+
+// PatternByName returns a pattern from Patterns
+func PatternByName(name string) (Pattern, error) {
+	pattern, ok := Patterns[name]
+	if !ok {
+		names := []string{}
+		for n := range Patterns {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("[Laser] unknown pattern '%s', use: %s", name, strings.Join(names, ", "))
+	}
+	return pattern, nil
+}
+
+// emitting returns pattern state after the time passed since the pattern start
+func (p Pattern) emitting(elapsed time.Duration) bool {
+	var total time.Duration
+	for _, d := range p {
+		total += d
+	}
+	if total <= 0 {
+		return true
+	}
+
+	elapsed %= total
+	for i, d := range p {
+		if elapsed < d {
+			return i%2 == 0
+		}
+		elapsed -= d
+	}
+	return false
+}
+
+// Laser controls the laser emitter connected to the pin
+type Laser struct {
+	Pin       Pin
+	MaxOnTime time.Duration // max emission time without a cool down, 0 - no limit
+	CoolDown  time.Duration // time the laser is forced off after max on time is reached
+
+	sync.Mutex
+	on           bool // requested state
+	pattern      Pattern
+	patternStart time.Time
+	emitting     bool          // actual pin state
+	onTime       time.Duration // emission time since the last cool down, pattern blinks don't reset it
+	offSince     time.Time
+	lastUpdate   time.Time
+	coolUntil    time.Time
+	closed       bool
+	stopCh       chan struct{}
+}
+
+// update sets the pin according to requested state, pattern and duty limit
+func (l *Laser) update(now time.Time) {
+	l.Lock()
+	defer l.Unlock()
+
+	emitting := l.on && !l.closed && now.After(l.coolUntil)
+	if emitting && l.pattern != nil {
+		emitting = l.pattern.emitting(now.Sub(l.patternStart))
+	}
+
+	// the laser cools down only if it's off long enough, short pattern pauses don't count
+	if l.emitting {
+		l.onTime += now.Sub(l.lastUpdate)
+	} else if now.Sub(l.offSince) >= l.CoolDown {
+		l.onTime = 0
+	}
+	l.lastUpdate = now
+
+	if emitting && l.MaxOnTime > 0 && l.onTime >= l.MaxOnTime {
+		l.coolUntil = now.Add(l.CoolDown)
+		l.onTime = 0
+		emitting = false
+		fmt.Printf("[Laser] max on time %s is reached, cool down for %s\n", l.MaxOnTime, l.CoolDown)
+	}
+
+	if emitting == l.emitting {
+		return
+	}
+	if emitting {
+		l.Pin.High()
+	} else {
+		l.Pin.Low()
+		l.offSince = now
+	}
+	l.emitting = emitting
+}
+
+// On turns the laser on (it blinks if a pattern is set)
+func (l *Laser) On() {
+	l.Lock()
+	if !l.on {
+		l.on = true
+		l.patternStart = time.Now()
+	}
+	l.Unlock()
+
+	l.update(time.Now())
+}
+
+// Off turns the laser off
+func (l *Laser) Off() {
+	l.Lock()
+	l.on = false
+	l.Unlock()
+
+	l.update(time.Now())
+}
+
+// SetPattern sets blink pattern, nil - steady light
+func (l *Laser) SetPattern(pattern Pattern) {
+	l.Lock()
+	l.pattern = pattern
+	l.patternStart = time.Now()
+	l.Unlock()
+
+	l.update(time.Now())
+}
+
+// IsOn returns true if the laser is emitting right now
+func (l *Laser) IsOn() bool {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.emitting
+}
+
+// Close turns the laser off permanently
+func (l *Laser) Close() {
+	l.Lock()
+	if l.closed {
+		l.Unlock()
+		return
+	}
+	l.closed = true
+	close(l.stopCh)
+	l.Unlock()
+
+	l.update(time.Now())
+	l.Pin.Low()
+}
+
+// Guard turns the laser off if the goroutine panics, use: defer laser.Guard()
+func (l *Laser) Guard() {
+	if r := recover(); r != nil {
+		// no locking, the panic may happen while the lock is held
+		l.Pin.Low()
+		panic(r)
+	}
+}
+
+// NewLaser creates new laser controller, the laser is off
+func NewLaser(pin Pin, maxOnTime, coolDown time.Duration) *Laser {
+	if coolDown == 0 {
+		coolDown = defaultCoolDown
+	}
+
+	l := &Laser{
+		Pin:       pin,
+		MaxOnTime: maxOnTime,
+		CoolDown:  coolDown,
+		stopCh:    make(chan struct{}),
+	}
+	l.Pin.Low()
+
+	// any guarded goroutine panic turns the laser off, no locking: the lock may be held
+	safety.OnPanic(pin.Low)
+
+	go func() {
+		defer l.Guard()
+
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stopCh:
+				return
+			case now := <-ticker.C:
+				l.update(now)
+			}
+		}
+	}()
+
+	return l
+}
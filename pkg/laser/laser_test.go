@@ -0,0 +1,123 @@
+package laser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/safety"
+)
+
+// newTestLaser creates a laser without background ticker, time is driven by update calls
+func newTestLaser(pattern Pattern, maxOnTime, coolDown time.Duration, start time.Time) (*Laser, *MemoryPin) {
+	pin := NewMemoryPin()
+	l := &Laser{
+		Pin:          pin,
+		MaxOnTime:    maxOnTime,
+		CoolDown:     coolDown,
+		on:           true,
+		pattern:      pattern,
+		patternStart: start,
+		lastUpdate:   start,
+		stopCh:       make(chan struct{}),
+	}
+	return l, pin
+}
+
+// run updates the laser every tick, returns the time the pin went low after max on time, zero if it did not
+func run(l *Laser, pin *MemoryPin, start time.Time, duration time.Duration) time.Duration {
+	for elapsed := time.Duration(0); elapsed <= duration; elapsed += tickInterval {
+		l.update(start.Add(elapsed))
+		if !l.coolUntil.IsZero() {
+			if pin.IsHigh() {
+				return -1
+			}
+			return elapsed
+		}
+	}
+	return 0
+}
+
+func TestMaxOnTime(t *testing.T) {
+	start := time.Now()
+	maxOnTime := time.Second
+
+	tests := []struct {
+		name    string
+		pattern Pattern
+		limit   time.Duration // max on time is reached in [maxOnTime, limit]
+	}{
+		{"steady", nil, maxOnTime},
+		{"long on, short off", Pattern{900 * time.Millisecond, 50 * time.Millisecond}, 1100 * time.Millisecond},
+		{"blink", Patterns["blink"], 2 * maxOnTime},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l, pin := newTestLaser(test.pattern, maxOnTime, 500*time.Millisecond, start)
+
+			elapsed := run(l, pin, start, 10*maxOnTime)
+			if elapsed < 0 {
+				t.Fatal("pin is high in cool down")
+			}
+			if elapsed < maxOnTime || elapsed > test.limit {
+				t.Errorf("max on time is reached after %s, expected in [%s, %s]", elapsed, maxOnTime, test.limit)
+			}
+		})
+	}
+}
+
+func TestMaxOnTimeResetAfterCoolDown(t *testing.T) {
+	start := time.Now()
+	l, pin := newTestLaser(nil, time.Second, 500*time.Millisecond, start)
+
+	// off for less than cool down, on time keeps counting
+	l.update(start)
+	l.update(start.Add(700 * time.Millisecond))
+	l.on = false
+	l.update(start.Add(800 * time.Millisecond))
+	l.on = true
+	l.update(start.Add(1000 * time.Millisecond))
+	l.update(start.Add(1350 * time.Millisecond))
+	if pin.IsHigh() {
+		t.Fatal("short pause resets on time")
+	}
+
+	// off for cool down, on time is reset
+	l.coolUntil = time.Time{}
+	l.on = false
+	l.update(start.Add(5 * time.Second))
+	l.on = true
+	l.update(start.Add(6 * time.Second))
+	l.update(start.Add(6900 * time.Millisecond))
+	if !pin.IsHigh() {
+		t.Fatal("on time is not reset after cool down")
+	}
+}
+
+func TestGuardTurnsOffOnPanic(t *testing.T) {
+	pin := NewMemoryPin()
+	l := NewLaser(pin, 0, 0)
+	defer l.Close()
+
+	l.On()
+	if !pin.IsHigh() {
+		t.Fatal("laser is not on")
+	}
+
+	// any guarded goroutine, not only the laser one
+	recovered := make(chan interface{})
+	go func() {
+		defer func() {
+			recovered <- recover()
+		}()
+		defer safety.Guard()
+		panic("servo failure")
+	}()
+
+	if r := <-recovered; r != "servo failure" {
+		t.Fatalf("panic is not propagated: %v", r)
+	}
+	if pin.IsHigh() {
+		t.Error("laser is on after panic")
+	}
+}
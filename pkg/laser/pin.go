@@ -0,0 +1,63 @@
+package laser
+
+import (
+	"sync"
+)
+
+// Pin is a digital output the laser emitter is connected to, rpio.Pin satisfies it
+type Pin interface {
+	High()
+	Low()
+}
+
+// MemoryPin is an in-memory pin that records its state,
+// it doesn't require any hardware and can be used for testing and simulation
+type MemoryPin struct {
+	sync.Mutex
+
+	high     bool
+	switches int
+}
+
+// High sets the pin on
+func (p *MemoryPin) High() {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.high {
+		p.switches++
+	}
+	p.high = true
+}
+
+// Low sets the pin off
+func (p *MemoryPin) Low() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.high {
+		p.switches++
+	}
+	p.high = false
+}
+
+// IsHigh returns current pin state
+func (p *MemoryPin) IsHigh() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.high
+}
+
+// Switches returns number of state changes
+func (p *MemoryPin) Switches() int {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.switches
+}
+
+// NewMemoryPin creates new in-memory pin in low state
+func NewMemoryPin() *MemoryPin {
+	return &MemoryPin{}
+}
@@ -0,0 +1,287 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metric types of text exposition format
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// DurationBuckets are histogram buckets in seconds for processing times
+var DurationBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// metric is written in Prometheus text exposition format
+type metric interface {
+	write(w io.Writer)
+}
+
+// desc is a metric name, help and type
+type desc struct {
+	name string
+	help string
+	typ  string
+}
+
+func (d desc) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, d.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// writeLabeled writes values sorted by label value
+func writeLabeled(w io.Writer, name, label string, values map[string]float64) {
+	labelValues := make([]string, 0, len(values))
+	for l := range values {
+		labelValues = append(labelValues, l)
+	}
+	sort.Strings(labelValues)
+
+	for _, l := range labelValues {
+		fmt.Fprintf(w, "%s{%s=%q} %s\n", name, label, l, formatValue(values[l]))
+	}
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	value uint64 // the first field to be 64-bit aligned for atomic operations on 32-bit ARM
+
+	desc
+}
+
+// Inc increases the counter by 1
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+// Add increases the counter
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Value returns current counter value
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.writeHeader(w)
+	fmt.Fprintf(w, "%s %d\n", c.name, c.Value())
+}
+
+// CounterVec is a set of counters with different values of a single label
+type CounterVec struct {
+	desc
+	label string
+
+	sync.Mutex
+	counters map[string]*Counter
+}
+
+// WithLabel returns the counter for the label value
+func (v *CounterVec) WithLabel(value string) *Counter {
+	v.Lock()
+	defer v.Unlock()
+
+	c, ok := v.counters[value]
+	if !ok {
+		c = &Counter{}
+		v.counters[value] = c
+	}
+	return c
+}
+
+func (v *CounterVec) write(w io.Writer) {
+	v.Lock()
+	values := make(map[string]float64, len(v.counters))
+	for l, c := range v.counters {
+		values[l] = float64(c.Value())
+	}
+	v.Unlock()
+
+	v.writeHeader(w)
+	writeLabeled(w, v.name, v.label, values)
+}
+
+// Histogram counts observed values in buckets
+type Histogram struct {
+	desc
+
+	sync.Mutex
+	buckets []float64 // upper bounds
+	counts  []uint64  // not cumulative
+	sum     float64
+	count   uint64
+}
+
+// Observe adds the value to the histogram
+func (h *Histogram) Observe(v float64) {
+	h.Lock()
+	defer h.Unlock()
+
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// ObserveDuration adds the duration in seconds to the histogram
+func (h *Histogram) ObserveDuration(d time.Duration) {
+	h.Observe(d.Seconds())
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.writeHeader(w)
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", h.name, formatValue(bound), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatValue(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
+
+// funcMetric is a counter or gauge which value is read on scrape
+type funcMetric struct {
+	desc
+	value func() float64
+}
+
+func (m *funcMetric) write(w io.Writer) {
+	m.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", m.name, formatValue(m.value()))
+}
+
+// labeledFuncMetric is a counter or gauge which values by label are read on scrape
+type labeledFuncMetric struct {
+	desc
+	label  string
+	values func() map[string]float64
+}
+
+func (m *labeledFuncMetric) write(w io.Writer) {
+	m.writeHeader(w)
+	writeLabeled(w, m.name, m.label, m.values())
+}
+
+// Registry is a set of metrics exposed in Prometheus text format
+type Registry struct {
+	sync.Mutex
+	names   map[string]bool
+	metrics []metric
+}
+
+// register panics on duplicated name, metrics are registered on start
+func (r *Registry) register(name string, m metric) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.names[name] {
+		panic(fmt.Sprintf("[Metrics] metric '%s' is already registered", name))
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// NewCounter registers new counter
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{desc: desc{name: name, help: help, typ: typeCounter}}
+	r.register(name, c)
+	return c
+}
+
+// NewCounterVec registers new set of counters with the label
+func (r *Registry) NewCounterVec(name, help, label string) *CounterVec {
+	v := &CounterVec{
+		desc:     desc{name: name, help: help, typ: typeCounter},
+		label:    label,
+		counters: map[string]*Counter{},
+	}
+	r.register(name, v)
+	return v
+}
+
+// NewHistogram registers new histogram with the bucket upper bounds (sorted)
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{
+		desc:    desc{name: name, help: help, typ: typeHistogram},
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+	r.register(name, h)
+	return h
+}
+
+// NewCounterFunc registers a counter which value is counted elsewhere
+func (r *Registry) NewCounterFunc(name, help string, value func() float64) {
+	r.register(name, &funcMetric{desc: desc{name: name, help: help, typ: typeCounter}, value: value})
+}
+
+// NewLabeledCounterFunc registers a counter which values by the label are counted elsewhere
+func (r *Registry) NewLabeledCounterFunc(name, help, label string, values func() map[string]float64) {
+	r.register(name, &labeledFuncMetric{
+		desc:   desc{name: name, help: help, typ: typeCounter},
+		label:  label,
+		values: values,
+	})
+}
+
+// NewGaugeFunc registers a gauge which value is read on scrape
+func (r *Registry) NewGaugeFunc(name, help string, value func() float64) {
+	r.register(name, &funcMetric{desc: desc{name: name, help: help, typ: typeGauge}, value: value})
+}
+
+// Write writes all metrics in Prometheus text exposition format
+func (r *Registry) Write(w io.Writer) {
+	r.Lock()
+	metrics := make([]metric, len(r.metrics))
+	copy(metrics, r.metrics)
+	r.Unlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// HTTPHandler is a handler for HTTP server, ex. "/metrics" to scrape by Prometheus
+func (r *Registry) HTTPHandler(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Write(res)
+}
+
+// NewRegistry creates new empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		names: map[string]bool{},
+	}
+}
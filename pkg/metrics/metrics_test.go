@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrape(t *testing.T) {
+	r := NewRegistry()
+
+	frames := r.NewCounter("test_frames_total", "Received frames.")
+	frames.Inc()
+	frames.Add(2)
+
+	dropped := r.NewCounterVec("test_dropped_frames_total", "Dropped frames.", "stage")
+	dropped.WithLabel("stream").Inc()
+	dropped.WithLabel("detector").Add(4)
+	dropped.WithLabel("stream").Inc()
+
+	latency := r.NewHistogram("test_latency_seconds", "Frame latency.", []float64{0.01, 0.1, 1})
+	latency.ObserveDuration(5 * time.Millisecond)
+	latency.Observe(0.01) // upper bound is inclusive
+	latency.Observe(0.5)
+	latency.Observe(2)
+
+	r.NewGaugeFunc("test_clients", "Connected clients.", func() float64 { return 3 })
+	r.NewLabeledCounterFunc("test_restarts_total", "Restarts.", "reason", func() map[string]float64 {
+		return map[string]float64{"exit": 1, "stall": 2}
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(r.HTTPHandler))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if contentType := res.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("content type: %s", contentType)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP test_frames_total Received frames.
+# TYPE test_frames_total counter
+test_frames_total 3
+# HELP test_dropped_frames_total Dropped frames.
+# TYPE test_dropped_frames_total counter
+test_dropped_frames_total{stage="detector"} 4
+test_dropped_frames_total{stage="stream"} 2
+# HELP test_latency_seconds Frame latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.01"} 2
+test_latency_seconds_bucket{le="0.1"} 2
+test_latency_seconds_bucket{le="1"} 3
+test_latency_seconds_bucket{le="+Inf"} 4
+test_latency_seconds_sum 2.515
+test_latency_seconds_count 4
+# HELP test_clients Connected clients.
+# TYPE test_clients gauge
+test_clients 3
+# HELP test_restarts_total Restarts.
+# TYPE test_restarts_total counter
+test_restarts_total{reason="exit"} 1
+test_restarts_total{reason="stall"} 2
+`
+	if string(body) != expected {
+		t.Errorf("scraped:\n%s\nexpected:\n%s", body, expected)
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Test.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicated metric is registered")
+		}
+	}()
+	r.NewGaugeFunc("test_total", "Test.", func() float64 { return 0 })
+}
@@ -0,0 +1,725 @@
+package mjpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// AVI (RIFF) container with OpenDML (AVI 2.0) extension for files larger than 1GB:
+//
+// 	RIFF 'AVI '
+// 		LIST 'hdrl'
+// 			'avih' main header
+// 			LIST 'strl'
+// 				'strh' stream header, 'strf' BITMAPINFOHEADER, 'indx' super index (of 'ix00' indexes)
+// 			LIST 'odml'
+// 				'dmlh' total number of frames
+// 		LIST 'movi'
+// 			'00dc' JPEG image, ...
+// 			'ix00' standard index of the RIFF frames
+// 		'idx1' legacy index of the first RIFF frames
+// 	RIFF 'AVIX'
+// 		LIST 'movi'
+// 			'00dc' JPEG image, ...
+// 			'ix00' standard index of the RIFF frames
+// 	...
+
+var (
+	// aviMaxRiffSize is the size of RIFF segment (OpenDML readers expect 1GB)
+	aviMaxRiffSize int64 = 1024 * 1024 * 1024
+
+	// aviMaxRiffs is a capacity of the super index, space for it is reserved in the header
+	aviMaxRiffs = 256
+)
+
+const (
+	aviFlagHasIndex   = 0x10 // AVIF_HASINDEX
+	aviFlagKeyFrame   = 0x10 // AVIIF_KEYFRAME
+	aviIndexOfIndexes = 0x00 // AVI_INDEX_OF_INDEXES
+	aviIndexOfChunks  = 0x01 // AVI_INDEX_OF_CHUNKS
+
+	aviFrameChunkID = "00dc"
+	aviIndexChunkID = "ix00"
+)
+
+// ErrAVIClosed - frame is written after the file is closed
+var ErrAVIClosed = errors.New("[AVI Writer] writer is closed")
+
+// aviMainHeader is 'avih' chunk (MainAVIHeader)
+type aviMainHeader struct {
+	MicroSecPerFrame    uint32
+	MaxBytesPerSec      uint32
+	PaddingGranularity  uint32
+	Flags               uint32
+	TotalFrames         uint32 // frames in the first RIFF
+	InitialFrames       uint32
+	Streams             uint32
+	SuggestedBufferSize uint32
+	Width               uint32
+	Height              uint32
+	Reserved            [4]uint32
+}
+
+// aviStreamHeader is 'strh' chunk (AVIStreamHeader)
+type aviStreamHeader struct {
+	Type                [4]byte
+	Handler             [4]byte
+	Flags               uint32
+	Priority            uint16
+	Language            uint16
+	InitialFrames       uint32
+	Scale               uint32
+	Rate                uint32 // Rate / Scale = fps
+	Start               uint32
+	Length              uint32 // all frames
+	SuggestedBufferSize uint32
+	Quality             uint32
+	SampleSize          uint32
+	Frame               [4]int16
+}
+
+// aviBitmapInfoHeader is 'strf' chunk of video stream (BITMAPINFOHEADER)
+type aviBitmapInfoHeader struct {
+	Size          uint32
+	Width         int32
+	Height        int32
+	Planes        uint16
+	BitCount      uint16
+	Compression   [4]byte
+	SizeImage     uint32
+	XPelsPerMeter int32
+	YPelsPerMeter int32
+	ClrUsed       uint32
+	ClrImportant  uint32
+}
+
+// aviExtendedHeader is 'dmlh' chunk (ODMLExtendedAVIHeader)
+type aviExtendedHeader struct {
+	TotalFrames uint32
+	Reserved    [61]uint32
+}
+
+// aviIndexHeader is a header of 'indx' (AVISUPERINDEX) and 'ix00' (AVISTDINDEX) chunks
+type aviIndexHeader struct {
+	LongsPerEntry uint16
+	IndexSubType  uint8
+	IndexType     uint8
+	EntriesInUse  uint32
+	ChunkID       [4]byte
+	BaseOffset    uint64 // reserved in super index
+	Reserved      uint32
+}
+
+// aviSuperIndexEntry points to 'ix00' chunk
+type aviSuperIndexEntry struct {
+	Offset   uint64 // 'ix00' chunk position in the file
+	Size     uint32 // 'ix00' chunk size with the header
+	Duration uint32 // number of frames
+}
+
+// aviStdIndexEntry points to frame data
+type aviStdIndexEntry struct {
+	Offset uint32 // frame data position relative to index base offset
+	Size   uint32
+}
+
+// aviOldIndexEntry is 'idx1' entry
+type aviOldIndexEntry struct {
+	ChunkID [4]byte
+	Flags   uint32
+	Offset  uint32 // chunk position relative to 'movi' list type
+	Size    uint32
+}
+
+// aviFrame is a written frame chunk
+type aviFrame struct {
+	offset int64 // chunk header position in the file
+	size   uint32
+}
+
+func fourCC(s string) (cc [4]byte) {
+	copy(cc[:], s)
+	return cc
+}
+
+// size of a chunk with the header and padding to even size
+func aviChunkSize(dataSize int64) int64 {
+	return 8 + dataSize + dataSize%2
+}
+
+var (
+	aviMainHeaderSize     = int64(binary.Size(aviMainHeader{}))
+	aviStreamHeaderSize   = int64(binary.Size(aviStreamHeader{}))
+	aviBitmapInfoSize     = int64(binary.Size(aviBitmapInfoHeader{}))
+	aviExtendedHeaderSize = int64(binary.Size(aviExtendedHeader{}))
+	aviIndexHeaderSize    = int64(binary.Size(aviIndexHeader{}))
+	aviSuperIndexSize     = aviIndexHeaderSize + int64(aviMaxRiffs*binary.Size(aviSuperIndexEntry{}))
+	aviStdIndexEntrySize  = int64(binary.Size(aviStdIndexEntry{}))
+	aviOldIndexEntrySize  = int64(binary.Size(aviOldIndexEntry{}))
+)
+
+// AVIWriter writes JPEG images to AVI container as MJPG video stream,
+// the file is playable after Close, interrupted file can be finalized with RecoverAVI
+type AVIWriter struct {
+	w io.WriteSeeker
+
+	mainHeader   aviMainHeader
+	streamHeader aviStreamHeader
+
+	pos int64 // write position
+
+	// header chunks data positions
+	avihOffset int64
+	strhOffset int64
+	indxOffset int64
+	dmlhOffset int64
+
+	riffStart int64 // -1 if no RIFF is open
+	moviStart int64
+	frames    []aviFrame // frames of the open RIFF
+
+	totalFrames int
+	superIndex  []aviSuperIndexEntry
+	closed      bool
+}
+
+// write writes binary values in little endian
+func (a *AVIWriter) write(values ...interface{}) error {
+	buf := new(bytes.Buffer)
+	for _, v := range values {
+		if b, ok := v.([]byte); ok {
+			buf.Write(b)
+		} else if err := binary.Write(buf, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	n, err := a.w.Write(buf.Bytes())
+	a.pos += int64(n)
+	if err != nil {
+		return fmt.Errorf("[AVI Writer] cannot write, error: %v", err)
+	}
+
+	return nil
+}
+
+// writeAt overwrites a value at the position
+func (a *AVIWriter) writeAt(offset int64, v interface{}) error {
+	if _, err := a.w.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("[AVI Writer] cannot seek, error: %v", err)
+	}
+
+	pos := a.pos
+	a.pos = offset
+	err := a.write(v)
+	a.pos = pos
+
+	if _, seekErr := a.w.Seek(pos, io.SeekStart); seekErr != nil && err == nil {
+		err = fmt.Errorf("[AVI Writer] cannot seek, error: %v", seekErr)
+	}
+
+	return err
+}
+
+// writeHeader writes the first RIFF header with header list
+func (a *AVIWriter) writeHeader() error {
+	strlSize := 4 +
+		aviChunkSize(aviStreamHeaderSize) +
+		aviChunkSize(aviBitmapInfoSize) +
+		aviChunkSize(aviSuperIndexSize)
+	odmlSize := 4 + aviChunkSize(aviExtendedHeaderSize)
+	hdrlSize := 4 + aviChunkSize(aviMainHeaderSize) + 8 + strlSize + 8 + odmlSize
+
+	a.riffStart = a.pos
+	err := a.write(
+		[]byte("RIFF"), uint32(0), []byte("AVI "),
+		[]byte("LIST"), uint32(hdrlSize), []byte("hdrl"),
+		[]byte("avih"), uint32(aviMainHeaderSize),
+	)
+	if err != nil {
+		return err
+	}
+
+	a.avihOffset = a.pos
+	err = a.write(
+		a.mainHeader,
+		[]byte("LIST"), uint32(strlSize), []byte("strl"),
+		[]byte("strh"), uint32(aviStreamHeaderSize),
+	)
+	if err != nil {
+		return err
+	}
+
+	a.strhOffset = a.pos
+	err = a.write(
+		a.streamHeader,
+		[]byte("strf"), uint32(aviBitmapInfoSize),
+		aviBitmapInfoHeader{
+			Size:        uint32(aviBitmapInfoSize),
+			Width:       int32(a.mainHeader.Width),
+			Height:      int32(a.mainHeader.Height),
+			Planes:      1,
+			BitCount:    24,
+			Compression: fourCC("MJPG"),
+			SizeImage:   a.mainHeader.Width * a.mainHeader.Height * 3,
+		},
+		[]byte("indx"), uint32(aviSuperIndexSize),
+	)
+	if err != nil {
+		return err
+	}
+
+	a.indxOffset = a.pos
+	err = a.write(
+		aviIndexHeader{
+			LongsPerEntry: 4,
+			IndexType:     aviIndexOfIndexes,
+			ChunkID:       fourCC(aviFrameChunkID),
+		},
+		make([]aviSuperIndexEntry, aviMaxRiffs),
+		[]byte("LIST"), uint32(odmlSize), []byte("odml"),
+		[]byte("dmlh"), uint32(aviExtendedHeaderSize),
+	)
+	if err != nil {
+		return err
+	}
+
+	a.dmlhOffset = a.pos
+	err = a.write(
+		aviExtendedHeader{},
+		[]byte("LIST"), uint32(0), []byte("movi"),
+	)
+	a.moviStart = a.pos - 12
+
+	return err
+}
+
+// startRiff writes the next RIFF header with frames list
+func (a *AVIWriter) startRiff() error {
+	if len(a.superIndex) >= aviMaxRiffs {
+		return fmt.Errorf("[AVI Writer] max file size is reached (%d RIFF segments)", aviMaxRiffs)
+	}
+
+	a.riffStart = a.pos
+	a.moviStart = a.pos + 12
+
+	return a.write(
+		[]byte("RIFF"), uint32(0), []byte("AVIX"),
+		[]byte("LIST"), uint32(0), []byte("movi"),
+	)
+}
+
+// finishRiff writes indexes of the open RIFF and sizes of its lists
+func (a *AVIWriter) finishRiff() error {
+	// standard index, offsets are relative to 'movi' list
+	indexStart := a.pos
+	indexSize := aviIndexHeaderSize + int64(len(a.frames))*aviStdIndexEntrySize
+	entries := make([]aviStdIndexEntry, len(a.frames))
+	for i, f := range a.frames {
+		entries[i] = aviStdIndexEntry{
+			Offset: uint32(f.offset + 8 - a.moviStart),
+			Size:   f.size,
+		}
+	}
+	err := a.write(
+		[]byte(aviIndexChunkID), uint32(indexSize),
+		aviIndexHeader{
+			LongsPerEntry: 2,
+			IndexType:     aviIndexOfChunks,
+			EntriesInUse:  uint32(len(a.frames)),
+			ChunkID:       fourCC(aviFrameChunkID),
+			BaseOffset:    uint64(a.moviStart),
+		},
+		entries,
+	)
+	if err != nil {
+		return err
+	}
+	a.superIndex = append(a.superIndex, aviSuperIndexEntry{
+		Offset:   uint64(indexStart),
+		Size:     uint32(8 + indexSize),
+		Duration: uint32(len(a.frames)),
+	})
+
+	if err := a.writeAt(a.moviStart+4, uint32(a.pos-a.moviStart-8)); err != nil {
+		return err
+	}
+
+	// legacy index for old players, offsets are relative to 'movi' list type
+	if a.riffStart == 0 {
+		oldEntries := make([]aviOldIndexEntry, len(a.frames))
+		for i, f := range a.frames {
+			oldEntries[i] = aviOldIndexEntry{
+				ChunkID: fourCC(aviFrameChunkID),
+				Flags:   aviFlagKeyFrame,
+				Offset:  uint32(f.offset - a.moviStart - 8),
+				Size:    f.size,
+			}
+		}
+		err := a.write([]byte("idx1"), uint32(int64(len(oldEntries))*aviOldIndexEntrySize), oldEntries)
+		if err != nil {
+			return err
+		}
+		a.mainHeader.TotalFrames = uint32(len(a.frames))
+	}
+
+	if err := a.writeAt(a.riffStart+4, uint32(a.pos-a.riffStart-8)); err != nil {
+		return err
+	}
+
+	a.riffStart = -1
+	a.frames = nil
+
+	return nil
+}
+
+// WriteFrame adds JPEG image to the video
+func (a *AVIWriter) WriteFrame(data []byte) error {
+	if a.closed {
+		return ErrAVIClosed
+	}
+
+	// indexes are written to the end of RIFF too
+	size := aviChunkSize(int64(len(data)))
+	indexesSize := 8 + aviIndexHeaderSize + int64(len(a.frames)+1)*aviStdIndexEntrySize
+	if a.riffStart == 0 {
+		indexesSize += 8 + int64(len(a.frames)+1)*aviOldIndexEntrySize
+	}
+	if a.riffStart >= 0 && len(a.frames) > 0 && a.pos+size+indexesSize-a.riffStart > aviMaxRiffSize {
+		if err := a.finishRiff(); err != nil {
+			return err
+		}
+	}
+	if a.riffStart < 0 {
+		if err := a.startRiff(); err != nil {
+			return err
+		}
+	}
+
+	a.frames = append(a.frames, aviFrame{offset: a.pos, size: uint32(len(data))})
+	a.totalFrames++
+	if uint32(len(data)) > a.mainHeader.SuggestedBufferSize {
+		a.mainHeader.SuggestedBufferSize = uint32(len(data))
+		a.streamHeader.SuggestedBufferSize = uint32(len(data))
+	}
+
+	if err := a.write([]byte(aviFrameChunkID), uint32(len(data)), data); err != nil {
+		return err
+	}
+	if len(data)%2 == 1 {
+		return a.write([]byte{0})
+	}
+
+	return nil
+}
+
+// Frames returns number of written frames
+func (a *AVIWriter) Frames() int {
+	return a.totalFrames
+}
+
+// Size returns the file size
+func (a *AVIWriter) Size() int64 {
+	return a.pos
+}
+
+// Close writes indexes and updates headers, the underlying writer is not closed
+func (a *AVIWriter) Close() error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+
+	if a.riffStart >= 0 {
+		if err := a.finishRiff(); err != nil {
+			return err
+		}
+	}
+
+	a.streamHeader.Length = uint32(a.totalFrames)
+
+	superIndexHeader := aviIndexHeader{
+		LongsPerEntry: 4,
+		IndexType:     aviIndexOfIndexes,
+		EntriesInUse:  uint32(len(a.superIndex)),
+		ChunkID:       fourCC(aviFrameChunkID),
+	}
+
+	// super index header marks the file as finalized for RecoverAVI, so it's written the last
+	for _, patch := range []struct {
+		offset int64
+		value  interface{}
+	}{
+		{a.avihOffset, a.mainHeader},
+		{a.strhOffset, a.streamHeader},
+		{a.indxOffset + aviIndexHeaderSize, a.superIndex},
+		{a.dmlhOffset, aviExtendedHeader{TotalFrames: uint32(a.totalFrames)}},
+		{a.indxOffset, superIndexHeader},
+	} {
+		if err := a.writeAt(patch.offset, patch.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewAVIWriter writes AVI header and returns the writer of JPEG images with the size
+func NewAVIWriter(w io.WriteSeeker, width, height, fps int) (*AVIWriter, error) {
+	if width <= 0 || height <= 0 || fps <= 0 {
+		return nil, fmt.Errorf("[AVI Writer] invalid video format: %dx%d@%d", width, height, fps)
+	}
+
+	pos, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("[AVI Writer] cannot seek, error: %v", err)
+	}
+	if pos != 0 {
+		return nil, fmt.Errorf("[AVI Writer] file must be empty")
+	}
+
+	a := &AVIWriter{
+		w: w,
+		mainHeader: aviMainHeader{
+			MicroSecPerFrame: uint32(1000000 / fps),
+			Flags:            aviFlagHasIndex,
+			Streams:          1,
+			Width:            uint32(width),
+			Height:           uint32(height),
+		},
+		streamHeader: aviStreamHeader{
+			Type:    fourCC("vids"),
+			Handler: fourCC("MJPG"),
+			Scale:   1,
+			Rate:    uint32(fps),
+			Quality: 0xFFFFFFFF, // default quality
+			Frame:   [4]int16{0, 0, int16(width), int16(height)},
+		},
+	}
+
+	if err := a.writeHeader(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// aviChunk is a chunk header read from the file
+type aviChunk struct {
+	offset int64 // header position
+	id     string
+	size   int64  // data size
+	form   string // list type for RIFF and LIST chunks
+}
+
+// end returns the position after the chunk with padding
+func (c aviChunk) end() int64 {
+	return c.offset + aviChunkSize(c.size)
+}
+
+// readAVIChunk reads the chunk header, returns false if the header is out of the limit
+func readAVIChunk(r io.ReaderAt, offset, limit int64) (aviChunk, bool) {
+	if offset+12 > limit {
+		return aviChunk{}, false
+	}
+
+	buf := make([]byte, 12)
+	if _, err := r.ReadAt(buf, offset); err != nil {
+		return aviChunk{}, false
+	}
+
+	c := aviChunk{
+		offset: offset,
+		id:     string(buf[:4]),
+		size:   int64(binary.LittleEndian.Uint32(buf[4:8])),
+	}
+	if c.id == "RIFF" || c.id == "LIST" {
+		c.form = string(buf[8:12])
+	}
+	return c, true
+}
+
+// readAVIValue reads binary value in little endian
+func readAVIValue(r io.ReaderAt, offset int64, v interface{}) error {
+	return binary.Read(io.NewSectionReader(r, offset, int64(binary.Size(v))), binary.LittleEndian, v)
+}
+
+// recoverHeader finds header chunks in 'hdrl' list, returns false if the file is already finalized
+func (a *AVIWriter) recoverHeader(r io.ReaderAt, hdrl aviChunk) (bool, error) {
+	lists := []aviChunk{hdrl}
+	for len(lists) > 0 {
+		list := lists[0]
+		lists = lists[1:]
+
+		for offset := list.offset + 12; offset < list.offset+8+list.size; {
+			c, ok := readAVIChunk(r, offset, list.offset+8+list.size)
+			if !ok {
+				break
+			}
+
+			var err error
+			switch c.id {
+			case "LIST":
+				lists = append(lists, c)
+			case "avih":
+				a.avihOffset = c.offset + 8
+				err = readAVIValue(r, a.avihOffset, &a.mainHeader)
+			case "strh":
+				a.strhOffset = c.offset + 8
+				err = readAVIValue(r, a.strhOffset, &a.streamHeader)
+			case "indx":
+				a.indxOffset = c.offset + 8
+				var header aviIndexHeader
+				err = readAVIValue(r, a.indxOffset, &header)
+				if err == nil && header.EntriesInUse > 0 {
+					return false, nil
+				}
+			case "dmlh":
+				a.dmlhOffset = c.offset + 8
+			}
+			if err != nil {
+				return false, fmt.Errorf("[AVI Writer] cannot read '%s' header, error: %v", c.id, err)
+			}
+
+			offset = c.end()
+		}
+	}
+
+	if a.avihOffset == 0 || a.strhOffset == 0 || a.indxOffset == 0 || a.dmlhOffset == 0 {
+		return false, fmt.Errorf("[AVI Writer] header is incomplete")
+	}
+
+	return true, nil
+}
+
+// recoverFrames reads frame chunks of 'movi' list till the limit or the first incomplete chunk,
+// returns index chunk of the list if any and the position after the last frame
+func (a *AVIWriter) recoverFrames(r io.ReaderAt, movi aviChunk, limit int64) (frames []aviFrame, index *aviChunk, end int64) {
+	end = movi.offset + 12
+	for offset := end; ; {
+		c, ok := readAVIChunk(r, offset, limit)
+		if !ok || offset+8+c.size > limit {
+			break
+		}
+
+		if c.id == aviFrameChunkID {
+			frames = append(frames, aviFrame{offset: c.offset, size: uint32(c.size)})
+			if uint32(c.size) > a.mainHeader.SuggestedBufferSize {
+				a.mainHeader.SuggestedBufferSize = uint32(c.size)
+				a.streamHeader.SuggestedBufferSize = uint32(c.size)
+			}
+			end = c.end()
+		} else if c.id == aviIndexChunkID {
+			index = &c
+		} else {
+			break
+		}
+
+		offset = c.end()
+	}
+
+	return frames, index, end
+}
+
+// RecoverAVI finalizes AVI file which writing was interrupted (ex. by power loss): the incomplete frame
+// is cut off, indexes are written and headers are updated; returns number of frames of the recovered file,
+// false if the file is already finalized
+func RecoverAVI(path string) (int, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, false, fmt.Errorf("[AVI Writer] cannot open file '%s', error: %v", path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, false, fmt.Errorf("[AVI Writer] cannot read file '%s', error: %v", path, err)
+	}
+	fileSize := info.Size()
+
+	riff, ok := readAVIChunk(f, 0, fileSize)
+	if !ok || riff.id != "RIFF" || riff.form != "AVI " {
+		return 0, false, fmt.Errorf("[AVI Writer] file '%s' is not AVI", path)
+	}
+
+	a := &AVIWriter{w: f, riffStart: -1}
+
+	hdrl, ok := readAVIChunk(f, 12, fileSize)
+	if !ok || hdrl.form != "hdrl" || hdrl.end() > fileSize {
+		return 0, false, fmt.Errorf("[AVI Writer] file '%s' has no header", path)
+	}
+	incomplete, err := a.recoverHeader(f, hdrl)
+	if err != nil || !incomplete {
+		return 0, false, err
+	}
+
+	// finished RIFFs have sizes and indexes, the last one is unfinished
+	end := int64(0)
+	for ok {
+		finished := riff.size > 0 && riff.end() <= fileSize
+		limit := fileSize
+		if finished {
+			limit = riff.offset + 8 + riff.size
+		}
+
+		// 'movi' list follows the header in the first RIFF
+		var movi aviChunk
+		for offset := riff.offset + 12; ; {
+			c, found := readAVIChunk(f, offset, limit)
+			if !found || (c.id == "LIST" && c.form == "movi") {
+				movi, ok = c, found
+				break
+			}
+			offset = c.end()
+		}
+		if !ok {
+			break
+		}
+
+		frames, index, framesEnd := a.recoverFrames(f, movi, limit)
+		if riff.offset == 0 {
+			a.mainHeader.TotalFrames = uint32(len(frames))
+		}
+		a.totalFrames += len(frames)
+
+		if !finished || index == nil {
+			a.riffStart = riff.offset
+			a.moviStart = movi.offset
+			a.frames = frames
+			end = framesEnd
+			break
+		}
+
+		a.superIndex = append(a.superIndex, aviSuperIndexEntry{
+			Offset:   uint64(index.offset),
+			Size:     uint32(8 + index.size),
+			Duration: uint32(len(frames)),
+		})
+		end = riff.end()
+
+		riff, ok = readAVIChunk(f, end, fileSize)
+		ok = ok && riff.id == "RIFF" && riff.form == "AVIX"
+	}
+
+	if end == 0 {
+		return 0, false, fmt.Errorf("[AVI Writer] file '%s' has no frames list", path)
+	}
+
+	if err := f.Truncate(end); err != nil {
+		return 0, false, fmt.Errorf("[AVI Writer] cannot truncate file '%s', error: %v", path, err)
+	}
+	a.pos = end
+	if _, err := f.Seek(end, io.SeekStart); err != nil {
+		return 0, false, fmt.Errorf("[AVI Writer] cannot seek, error: %v", err)
+	}
+
+	if err := a.Close(); err != nil {
+		return 0, false, err
+	}
+
+	return a.totalFrames, true, f.Sync()
+}
@@ -0,0 +1,351 @@
+package mjpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testFrames returns JPEG-like frames of different (odd and even) sizes
+func testFrames(n int) [][]byte {
+	frames := make([][]byte, n)
+	for i := range frames {
+		data := []byte{0xFF, markerSOI}
+		data = append(data, bytes.Repeat([]byte(fmt.Sprintf("frame %d;", i)), 20+i%7)...)
+		frames[i] = append(data, 0xFF, markerEOI, byte(i%2))[:len(data)+2+i%2]
+	}
+	return frames
+}
+
+// aviFile is a parsed AVI file
+type aviFile struct {
+	riffs        int
+	frames       [][]byte
+	mainHeader   aviMainHeader
+	streamHeader aviStreamHeader
+	totalFrames  int // 'dmlh'
+	superIndex   []aviSuperIndexEntry
+	oldIndex     int // 'idx1' entries
+}
+
+// findAVIChunk finds the chunk in the list and its sub-lists
+func findAVIChunk(r *bytes.Reader, list aviChunk, id string) (aviChunk, bool) {
+	for offset := list.offset + 12; offset < list.end(); {
+		c, ok := readAVIChunk(r, offset, list.end())
+		if !ok {
+			return aviChunk{}, false
+		}
+		if c.id == id {
+			return c, true
+		}
+		if c.id == "LIST" {
+			if found, ok := findAVIChunk(r, c, id); ok {
+				return found, true
+			}
+		}
+		offset = c.end()
+	}
+	return aviChunk{}, false
+}
+
+// readTestAVI parses the file and checks chunk sizes and indexes
+func readTestAVI(t *testing.T, path string) aviFile {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(data)
+	size := int64(len(data))
+
+	var avi aviFile
+	var superIndexHeader aviIndexHeader
+	var extendedHeader aviExtendedHeader
+	var firstMovi int64
+	indexedFrames := map[int64]uint32{} // by data position
+
+	for offset := int64(0); offset < size; {
+		riff, ok := readAVIChunk(r, offset, size)
+		if !ok || riff.id != "RIFF" || riff.end() > size {
+			t.Fatalf("invalid RIFF at %d: %+v", offset, riff)
+		}
+		avi.riffs++
+
+		for o := riff.offset + 12; o < riff.end(); {
+			c, ok := readAVIChunk(r, o, riff.end())
+			if !ok || c.end() > riff.end() {
+				t.Fatalf("invalid chunk at %d: %+v", o, c)
+			}
+
+			switch {
+			case c.id == "LIST" && c.form == "hdrl":
+				for _, header := range []struct {
+					id    string
+					value interface{}
+				}{
+					{"avih", &avi.mainHeader},
+					{"strh", &avi.streamHeader},
+					{"indx", &superIndexHeader},
+					{"dmlh", &extendedHeader},
+				} {
+					h, ok := findAVIChunk(r, c, header.id)
+					if !ok {
+						t.Fatalf("no '%s' header", header.id)
+					}
+					if err := readAVIValue(r, h.offset+8, header.value); err != nil {
+						t.Fatal(err)
+					}
+					if header.id == "indx" {
+						avi.superIndex = make([]aviSuperIndexEntry, superIndexHeader.EntriesInUse)
+						if err := readAVIValue(r, h.offset+8+aviIndexHeaderSize, avi.superIndex); err != nil {
+							t.Fatal(err)
+						}
+					}
+				}
+				avi.totalFrames = int(extendedHeader.TotalFrames)
+
+			case c.id == "LIST" && c.form == "movi":
+				if firstMovi == 0 {
+					firstMovi = c.offset
+				}
+				for fo := c.offset + 12; fo < c.end(); {
+					f, ok := readAVIChunk(r, fo, c.end())
+					if !ok || f.end() > c.end() {
+						t.Fatalf("invalid frame list chunk at %d", fo)
+					}
+					switch f.id {
+					case aviFrameChunkID:
+						avi.frames = append(avi.frames, data[f.offset+8:f.offset+8+f.size])
+					case aviIndexChunkID:
+						var header aviIndexHeader
+						if err := readAVIValue(r, f.offset+8, &header); err != nil {
+							t.Fatal(err)
+						}
+						entries := make([]aviStdIndexEntry, header.EntriesInUse)
+						if err := readAVIValue(r, f.offset+8+aviIndexHeaderSize, entries); err != nil {
+							t.Fatal(err)
+						}
+						for _, e := range entries {
+							indexedFrames[int64(header.BaseOffset)+int64(e.Offset)] = e.Size
+						}
+					default:
+						t.Fatalf("unexpected chunk '%s' in frames list", f.id)
+					}
+					fo = f.end()
+				}
+
+			case c.id == "idx1":
+				entries := make([]aviOldIndexEntry, c.size/aviOldIndexEntrySize)
+				if err := readAVIValue(r, c.offset+8, entries); err != nil {
+					t.Fatal(err)
+				}
+				for _, e := range entries {
+					if string(data[firstMovi+8+int64(e.Offset):][:4]) != aviFrameChunkID {
+						t.Fatalf("'idx1' entry doesn't point to a frame: %+v", e)
+					}
+				}
+				avi.oldIndex = len(entries)
+			}
+
+			o = c.end()
+		}
+
+		offset = riff.end()
+	}
+
+	// every frame is indexed
+	if len(indexedFrames) != len(avi.frames) {
+		t.Errorf("indexed frames: %d, frames: %d", len(indexedFrames), len(avi.frames))
+	}
+	for position, size := range indexedFrames {
+		if position < 8 || string(data[position-8:position-4]) != aviFrameChunkID ||
+			binary.LittleEndian.Uint32(data[position-4:position]) != size {
+			t.Errorf("'ix00' entry doesn't point to a frame: %d", position)
+		}
+	}
+	if len(avi.superIndex) != avi.riffs {
+		t.Errorf("super index entries: %d, RIFFs: %d", len(avi.superIndex), avi.riffs)
+	}
+	for _, e := range avi.superIndex {
+		if string(data[e.Offset:e.Offset+4]) != aviIndexChunkID {
+			t.Errorf("super index entry doesn't point to 'ix00': %+v", e)
+		}
+	}
+
+	return avi
+}
+
+// checkTestAVI checks the file has the frames and all headers are updated
+func checkTestAVI(t *testing.T, path string, frames [][]byte, minRiffs int) aviFile {
+	t.Helper()
+
+	avi := readTestAVI(t, path)
+	if len(avi.frames) != len(frames) {
+		t.Fatalf("frames: %d, expected: %d", len(avi.frames), len(frames))
+	}
+	for i := range frames {
+		if !bytes.Equal(avi.frames[i], frames[i]) {
+			t.Fatalf("frame %d differs", i)
+		}
+	}
+	if avi.riffs < minRiffs {
+		t.Errorf("RIFFs: %d, expected at least: %d", avi.riffs, minRiffs)
+	}
+	if avi.totalFrames != len(frames) || int(avi.streamHeader.Length) != len(frames) {
+		t.Errorf("total frames: %d (dmlh), %d (strh), expected: %d", avi.totalFrames, avi.streamHeader.Length, len(frames))
+	}
+	if int(avi.mainHeader.TotalFrames) != avi.oldIndex || avi.oldIndex == 0 {
+		t.Errorf("frames of the first RIFF: %d (avih), %d (idx1)", avi.mainHeader.TotalFrames, avi.oldIndex)
+	}
+	return avi
+}
+
+// writeTestAVI writes the frames, the file is not finalized if close is false
+func writeTestAVI(t *testing.T, path string, frames [][]byte, close bool) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	a, err := NewAVIWriter(f, 64, 48, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, frame := range frames {
+		if err := a.WriteFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.Frames() != len(frames) {
+		t.Errorf("frames: %d, expected: %d", a.Frames(), len(frames))
+	}
+	if close {
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.WriteFrame(frames[0]); err != ErrAVIClosed {
+			t.Errorf("expected closed writer error, got: %v", err)
+		}
+	}
+}
+
+// withRiffSize sets small RIFF size, so files have several RIFFs
+func withRiffSize(t *testing.T, size int64) {
+	maxRiffSize := aviMaxRiffSize
+	aviMaxRiffSize = size
+	t.Cleanup(func() {
+		aviMaxRiffSize = maxRiffSize
+	})
+}
+
+func TestAVIWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "avi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		riffSize int64
+		frames   int
+		minRiffs int
+	}{
+		{"single RIFF", aviMaxRiffSize, 30, 1},
+		{"OpenDML", 4096, 100, 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withRiffSize(t, test.riffSize)
+
+			path := filepath.Join(dir, test.name+".avi")
+			frames := testFrames(test.frames)
+			writeTestAVI(t, path, frames, true)
+
+			avi := checkTestAVI(t, path, frames, test.minRiffs)
+			if avi.mainHeader.Width != 64 || avi.mainHeader.Height != 48 || avi.streamHeader.Rate != 10 {
+				t.Errorf("video format: %dx%d@%d", avi.mainHeader.Width, avi.mainHeader.Height, avi.streamHeader.Rate)
+			}
+
+			// finalized file is not changed
+			frameCount, recovered, err := RecoverAVI(path)
+			if err != nil || recovered || frameCount != 0 {
+				t.Errorf("finalized file is recovered: %d frames, error: %v", frameCount, err)
+			}
+		})
+	}
+}
+
+func TestRecoverAVI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "avi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		riffSize int64
+		frames   int
+		cut      int // bytes cut off the end, the last frame is torn
+		minRiffs int
+	}{
+		{"complete frames", aviMaxRiffSize, 30, 0, 1},
+		{"torn frame", aviMaxRiffSize, 30, 50, 1},
+		{"torn frame header", aviMaxRiffSize, 30, len(testFrames(30)[29]) + 4, 1},
+		{"OpenDML, complete frames", 4096, 100, 0, 5},
+		{"OpenDML, torn frame", 4096, 100, 50, 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withRiffSize(t, test.riffSize)
+
+			path := filepath.Join(dir, test.name+".avi")
+			frames := testFrames(test.frames)
+			writeTestAVI(t, path, frames, false)
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Truncate(path, info.Size()-int64(test.cut)); err != nil {
+				t.Fatal(err)
+			}
+			if test.cut > 0 {
+				frames = frames[:len(frames)-1]
+			}
+
+			frameCount, recovered, err := RecoverAVI(path)
+			if err != nil || !recovered {
+				t.Fatalf("file is not recovered, error: %v", err)
+			}
+			if frameCount != len(frames) {
+				t.Errorf("recovered frames: %d, expected: %d", frameCount, len(frames))
+			}
+			checkTestAVI(t, path, frames, test.minRiffs)
+
+			if _, recovered, _ := RecoverAVI(path); recovered {
+				t.Error("file is recovered twice")
+			}
+		})
+	}
+
+	// not AVI
+	path := filepath.Join(dir, "frames.mjpeg")
+	if err := ioutil.WriteFile(path, bytes.Join(testFrames(3), nil), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := RecoverAVI(path); err == nil {
+		t.Error("not AVI file is recovered")
+	}
+}
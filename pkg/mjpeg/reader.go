@@ -0,0 +1,277 @@
+package mjpeg
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxFrameSize - default max size of a single JPEG image in the stream
+var DefaultMaxFrameSize = 4 * 1024 * 1024
+
+// JPEG markers (the second byte after 0xFF)
+const (
+	markerTEM  = 0x01 // temporary use in arithmetic coding, standalone
+	markerRST0 = 0xD0 // restart markers RST0-RST7, standalone
+	markerRST7 = 0xD7
+	markerSOI  = 0xD8 // start of image, standalone
+	markerEOI  = 0xD9 // end of image, standalone
+	markerSOS  = 0xDA // start of scan, followed by entropy-coded data
+)
+
+// ErrFrameTooLarge - frame exceeds Reader.MaxFrameSize
+var ErrFrameTooLarge = errors.New("frame is too large")
+
+// FrameError describes a corrupted frame, the frame is skipped and reading can be continued
+type FrameError struct {
+	Offset int64 // frame start position in the stream
+	Size   int   // number of frame bytes read before the error
+	Err    error
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("[MJPEG Reader] corrupted frame at %d (%d bytes read): %s", e.Offset, e.Size, e.Err)
+}
+
+// Reader splits MJPEG byte stream (concatenated JPEG images) into separate images.
+//
+// Frames are found by SOI/EOI markers, marker segments are skipped by their length,
+// so any bytes between images (multipart headers, garbage) are ignored.
+type Reader struct {
+	// MaxFrameSize is a limit of a single frame size, DefaultMaxFrameSize is used if 0
+	MaxFrameSize int
+
+	source        *bufio.Reader
+	frame         *bytes.Buffer
+	offset        int64 // number of bytes read from the source
+	pendingSOI    bool  // SOI of the next frame was already read
+	corruptFrames int
+}
+
+func (r *Reader) maxFrameSize() int {
+	if r.MaxFrameSize > 0 {
+		return r.MaxFrameSize
+	}
+	return DefaultMaxFrameSize
+}
+
+func (r *Reader) readByte() (byte, error) {
+	b, err := r.source.ReadByte()
+	if err == nil {
+		r.offset++
+	}
+	return b, err
+}
+
+func (r *Reader) write(b ...byte) error {
+	if r.frame.Len()+len(b) > r.maxFrameSize() {
+		return ErrFrameTooLarge
+	}
+	r.frame.Write(b)
+	return nil
+}
+
+// skipToSOI skips everything before SOI marker, returns the marker offset
+func (r *Reader) skipToSOI() (int64, error) {
+	if r.pendingSOI {
+		r.pendingSOI = false
+		return r.offset - 2, nil
+	}
+
+	var previous byte
+	for {
+		b, err := r.readByte()
+		if err != nil {
+			return r.offset, err
+		}
+		if previous == 0xFF && b == markerSOI {
+			return r.offset - 2, nil
+		}
+		previous = b
+	}
+}
+
+// readMarker reads a marker code skipping fill bytes (0xFF)
+func (r *Reader) readMarker() (byte, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0xFF {
+		return 0, fmt.Errorf("expected marker, got 0x%02X", b)
+	}
+
+	for b == 0xFF {
+		b, err = r.readByte()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if b == 0x00 {
+		return 0, errors.New("unexpected stuffed zero byte outside of scan data")
+	}
+
+	return b, nil
+}
+
+// readSegment reads marker segment payload (segment length includes two length bytes)
+func (r *Reader) readSegment() error {
+	var lengthBytes [2]byte
+	for i := range lengthBytes {
+		b, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		lengthBytes[i] = b
+	}
+
+	length := int(lengthBytes[0])<<8 | int(lengthBytes[1])
+	if length < 2 {
+		return fmt.Errorf("wrong segment length %d", length)
+	}
+	if r.frame.Len()+length > r.maxFrameSize() {
+		// skip the payload, so the next frame search doesn't find markers inside it (ex. EXIF thumbnail)
+		n, err := r.source.Discard(length - 2)
+		r.offset += int64(n)
+		if err != nil {
+			return err
+		}
+		return ErrFrameTooLarge
+	}
+
+	r.frame.Write(lengthBytes[:])
+	n, err := io.CopyN(r.frame, r.source, int64(length-2))
+	r.offset += n
+
+	return err
+}
+
+// readScanData reads entropy-coded data after SOS segment, returns the marker that ends the data
+func (r *Reader) readScanData() (byte, error) {
+	for {
+		chunk, err := r.source.ReadSlice(0xFF)
+		r.offset += int64(len(chunk))
+		if err == bufio.ErrBufferFull {
+			if writeErr := r.write(chunk...); writeErr != nil {
+				return 0, writeErr
+			}
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		// write data without the trailing 0xFF
+		if err := r.write(chunk[:len(chunk)-1]...); err != nil {
+			return 0, err
+		}
+
+		b, err := r.readByte()
+		if err != nil {
+			return 0, err
+		}
+		for b == 0xFF { // fill bytes
+			b, err = r.readByte()
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		// stuffed zero and restart markers are a part of scan data
+		if b == 0x00 || (b >= markerRST0 && b <= markerRST7) {
+			if err := r.write(0xFF, b); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		return b, nil
+	}
+}
+
+// readSegments reads frame marker segments after SOI till EOI
+func (r *Reader) readSegments() error {
+	var marker byte
+	var err error
+	markerRead := false
+
+	for {
+		if !markerRead {
+			marker, err = r.readMarker()
+			if err != nil {
+				return err
+			}
+		}
+		markerRead = false
+
+		switch {
+		case marker == markerSOI:
+			// previous frame is truncated, start the next one from this marker
+			r.pendingSOI = true
+			return errors.New("unexpected SOI marker")
+		case marker == markerEOI:
+			return r.write(0xFF, marker)
+		case marker == markerTEM || (marker >= markerRST0 && marker <= markerRST7):
+			err = r.write(0xFF, marker)
+		default:
+			err = r.write(0xFF, marker)
+			if err == nil {
+				err = r.readSegment()
+			}
+			if err == nil && marker == markerSOS {
+				marker, err = r.readScanData()
+				markerRead = true
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ReadFrame returns next JPEG image from the stream.
+//
+// *FrameError is returned for a corrupted or too large frame, the next call continues
+// with the following frame. io.EOF is returned when the stream ends.
+func (r *Reader) ReadFrame() ([]byte, error) {
+	r.frame.Reset()
+
+	offset, err := r.skipToSOI()
+	if err != nil {
+		return nil, err
+	}
+	r.frame.Write([]byte{0xFF, markerSOI})
+
+	err = r.readSegments()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		r.corruptFrames++
+		return nil, &FrameError{
+			Offset: offset,
+			Size:   r.frame.Len(),
+			Err:    err,
+		}
+	}
+
+	imageBytes := make([]byte, r.frame.Len())
+	copy(imageBytes, r.frame.Bytes())
+
+	return imageBytes, nil
+}
+
+// CorruptFrames returns the number of skipped corrupted frames
+func (r *Reader) CorruptFrames() int {
+	return r.corruptFrames
+}
+
+// NewReader creates new MJPEG stream reader
+func NewReader(source io.Reader) *Reader {
+	return &Reader{
+		source: bufio.NewReaderSize(source, 64*1024),
+		frame:  new(bytes.Buffer),
+	}
+}
@@ -0,0 +1,195 @@
+package mjpeg
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"io"
+	"testing"
+)
+
+// testJPEG encodes a small image, the color makes images different
+func testJPEG(t testing.TB, c uint8) []byte {
+	img := image.NewGray(image.Rect(0, 0, 16, 8))
+	for i := range img.Pix {
+		img.Pix[i] = c + uint8(i)
+	}
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// segment builds marker segment with the payload
+func segment(marker byte, payload []byte) []byte {
+	length := len(payload) + 2
+	return append([]byte{0xFF, marker, byte(length >> 8), byte(length)}, payload...)
+}
+
+// withEXIF inserts APP1 segment with EXIF thumbnail (a whole JPEG image) after SOI
+func withEXIF(image, thumbnail []byte) []byte {
+	payload := append([]byte("Exif\x00\x00"), thumbnail...)
+	data := append([]byte{}, image[:2]...)
+	data = append(data, segment(0xE1, payload)...)
+	return append(data, image[2:]...)
+}
+
+// restartJPEG is a minimal image with restart interval, restart markers and stuffed bytes in scan data
+func restartJPEG() []byte {
+	data := []byte{0xFF, markerSOI}
+	data = append(data, segment(0xE0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"))...)
+	data = append(data, segment(0xDD, []byte{0x00, 0x04})...) // DRI
+	data = append(data, segment(markerSOS, []byte{0x01, 0x01, 0x00, 0x00, 0x3F, 0x00})...)
+	data = append(data, 0x12, 0xFF, 0x00, 0x34, 0xFF, 0xD0, 0x56, 0xFF, 0xD1, 0x78, 0xFF, 0x00)
+	return append(data, 0xFF, markerEOI)
+}
+
+// readAll reads frames till the end of the stream, corrupted frames are counted
+func readAll(t testing.TB, r *Reader, limit int) (frames [][]byte, corrupted int) {
+	for i := 0; i <= limit; i++ {
+		frame, err := r.ReadFrame()
+		if err == io.EOF {
+			return frames, corrupted
+		}
+		if _, ok := err.(*FrameError); ok {
+			corrupted++
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		frames = append(frames, frame)
+	}
+	t.Fatalf("reader doesn't reach the end of %d bytes stream", limit)
+	return nil, 0
+}
+
+func TestReaderConcatenated(t *testing.T) {
+	images := [][]byte{
+		testJPEG(t, 0),
+		withEXIF(testJPEG(t, 10), testJPEG(t, 20)),
+		restartJPEG(),
+		testJPEG(t, 30),
+	}
+
+	var stream []byte
+	for i, image := range images {
+		if i == 2 {
+			stream = append(stream, "\r\n--frame\r\nContent-Type: image/jpeg\r\n\r\n"...) // multipart garbage
+		}
+		stream = append(stream, image...)
+	}
+
+	frames, corrupted := readAll(t, NewReader(bytes.NewReader(stream)), len(stream))
+	if corrupted != 0 {
+		t.Errorf("corrupted frames: %d", corrupted)
+	}
+	if len(frames) != len(images) {
+		t.Fatalf("frames: %d, expected: %d", len(frames), len(images))
+	}
+	for i := range images {
+		if !bytes.Equal(frames[i], images[i]) {
+			t.Errorf("frame %d differs from the image", i)
+		}
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	first := testJPEG(t, 0)
+	second := testJPEG(t, 50)
+
+	// a truncated marker segment takes bytes of the next frame as its payload,
+	// so the next frame can be read only if the frame is truncated in scan data
+	scan := bytes.Index(first, []byte{0xFF, markerSOS})
+	for _, cut := range []int{10, scan / 2} {
+		stream := append(append([]byte{}, first[:cut]...), second...)
+		if frames, _ := readAll(t, NewReader(bytes.NewReader(stream)), len(stream)); len(frames) > 1 {
+			t.Errorf("cut %d: %d frames are read", cut, len(frames))
+		}
+	}
+
+	for _, cut := range []int{3, scan + 20, len(first) - 2} {
+		stream := append(append([]byte{}, first[:cut]...), second...)
+		r := NewReader(bytes.NewReader(stream))
+
+		_, err := r.ReadFrame()
+		if _, ok := err.(*FrameError); !ok {
+			t.Errorf("cut %d: expected frame error, got: %v", cut, err)
+			continue
+		}
+
+		frame, err := r.ReadFrame()
+		if err != nil || !bytes.Equal(frame, second) {
+			t.Errorf("cut %d: the next frame is not read after truncated one, error: %v", cut, err)
+		}
+	}
+
+	// truncated last frame
+	r := NewReader(bytes.NewReader(first[:len(first)-10]))
+	if _, err := r.ReadFrame(); err == nil || r.CorruptFrames() != 1 {
+		t.Errorf("expected corrupted frame, got: %v", err)
+	}
+	if _, err := r.ReadFrame(); err != io.EOF {
+		t.Errorf("expected EOF, got: %v", err)
+	}
+}
+
+func TestReaderTooLargeSegment(t *testing.T) {
+	// large EXIF segment with a thumbnail, the thumbnail must not be read as a frame
+	large := withEXIF(testJPEG(t, 0), append(testJPEG(t, 90), make([]byte, 1024)...))
+	next := testJPEG(t, 60)
+	stream := append(append([]byte{}, large...), next...)
+
+	r := NewReader(bytes.NewReader(stream))
+	r.MaxFrameSize = 1024
+
+	_, err := r.ReadFrame()
+	if frameErr, ok := err.(*FrameError); !ok || frameErr.Err != ErrFrameTooLarge {
+		t.Fatalf("expected too large frame error, got: %v", err)
+	}
+
+	// the rest of the large frame is skipped by SOI search, scan data has no SOI markers
+	frames, _ := readAll(t, r, len(stream))
+	if len(frames) != 1 || !bytes.Equal(frames[0], next) {
+		t.Errorf("expected the next frame only, got %d frames", len(frames))
+	}
+}
+
+func FuzzReader(f *testing.F) {
+	first := testJPEG(f, 0)
+	second := testJPEG(f, 100)
+	exif := withEXIF(testJPEG(f, 10), testJPEG(f, 20))
+
+	f.Add(first)
+	f.Add(append(append([]byte{}, first...), second...))
+	f.Add(append(append([]byte{}, first[:len(first)/2]...), second...))
+	f.Add(first[:len(first)-1])
+	f.Add(exif)
+	f.Add(exif[:30])
+	f.Add(restartJPEG())
+	f.Add(append(restartJPEG(), restartJPEG()...))
+	f.Add([]byte{0xFF, 0xD8, 0xFF, 0xD8, 0xFF, 0xD9})
+	f.Add([]byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x01})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := NewReader(bytes.NewReader(data))
+		r.MaxFrameSize = 1024
+
+		frames, _ := readAll(t, r, len(data))
+		for _, frame := range frames {
+			if len(frame) > r.MaxFrameSize {
+				t.Fatalf("frame size %d exceeds the limit", len(frame))
+			}
+			if !bytes.HasPrefix(frame, []byte{0xFF, markerSOI}) || !bytes.HasSuffix(frame, []byte{0xFF, markerEOI}) {
+				t.Fatalf("frame has no SOI/EOI markers: % X", frame)
+			}
+
+			// a frame is read as is
+			again, err := NewReader(bytes.NewReader(frame)).ReadFrame()
+			if err != nil || !bytes.Equal(again, frame) {
+				t.Fatalf("frame is not read back, error: %v", err)
+			}
+		}
+	})
+}
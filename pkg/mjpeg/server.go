@@ -1,8 +1,10 @@
 package mjpeg
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"sync"
 )
 
 // Server streams MJPEG video to clients from specified source on specified URL
@@ -13,8 +15,15 @@ type Server struct {
 	// StreamURL is a sub-URL for stream, "/stream" means server will stream on "http://localhost:8081/stream"
 	StreamURL string
 
-	// Source is a channel of images represented as []byte
+	// Source is a channel of images represented as []byte, nil to serve only Handlers (no stream and index page)
 	Source chan []byte
+
+	// Handlers are additional handlers to serve on the same address, ex. {"/behavior": handler}
+	Handlers map[string]http.HandlerFunc
+
+	mu     sync.Mutex
+	server *http.Server
+	stream *Stream
 }
 
 // FullStreamURL returns full stream URL for links
@@ -55,21 +64,74 @@ func (s *Server) indexHandler(res http.ResponseWriter, req *http.Request) {
 // ListenAndServe starts the HTTP server that:
 // - has index page with stream demo
 // - streams MJPEG video on specified StreamURL
+// - serves additional Handlers
+// - returns http.ErrServerClosed after Shutdown
 func (s *Server) ListenAndServe() error {
-	// index page
-	if s.StreamURL != "/" {
-		http.HandleFunc("/", s.indexHandler)
+	mux := http.NewServeMux()
+
+	var stream *Stream
+	if s.Source != nil {
+		// index page
+		if s.StreamURL != "/" {
+			mux.HandleFunc("/", s.indexHandler)
+		}
+
+		// create MJPEG stream handler from source channel
+		stream = NewHandler(s.Source)
+		mux.HandleFunc(s.StreamURL, stream.HTTPHandler)
 	}
 
-	// create MJPEG stream handler from source channel
-	stream := NewHandler(s.Source)
-	http.HandleFunc(s.StreamURL, stream.HTTPHandler)
+	// additional handlers
+	for url, handler := range s.Handlers {
+		mux.HandleFunc(url, handler)
+	}
 
 	server := &http.Server{
-		Addr: s.Addr,
+		Addr:    s.Addr,
+		Handler: mux,
 	}
 
-	fmt.Printf("[MJPEG Server] streaming on %s\n", s.FullStreamURL())
+	s.mu.Lock()
+	s.server = server
+	s.stream = stream
+	s.mu.Unlock()
+
+	if stream != nil {
+		fmt.Printf("[MJPEG Server] streaming on %s\n", s.FullStreamURL())
+	} else {
+		fmt.Printf("[MJPEG Server] listening on %s\n", s.Addr)
+	}
 
 	return server.ListenAndServe()
 }
+
+// ClientCount returns number of connected stream clients
+func (s *Server) ClientCount() int {
+	s.mu.Lock()
+	stream := s.stream
+	s.mu.Unlock()
+
+	if stream == nil {
+		return 0
+	}
+	return stream.ClientCount()
+}
+
+// Shutdown disconnects stream clients and gracefully shuts down the server
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	server := s.server
+	stream := s.stream
+	s.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+
+	if stream != nil {
+		stream.Close()
+	}
+	fmt.Println("[MJPEG Server] shutdown")
+
+	return server.Shutdown(ctx)
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/frame"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/safety"
 )
 
 var mjpegBoundary = "--CUT-HERE"
@@ -14,6 +17,8 @@ type Stream struct {
 	sync.Mutex
 	Source  chan []byte
 	clients []chan []byte
+	closed  bool
+	doneCh  chan struct{}
 }
 
 func (s *Stream) addClient(client chan []byte) {
@@ -37,22 +42,35 @@ func (s *Stream) removeClient(clientToRemove chan []byte) {
 }
 
 func (s *Stream) logClients() {
+	fmt.Printf("[MJPEG Stream] client count: %d\n", s.ClientCount())
+}
+
+// ClientCount returns number of connected clients
+func (s *Stream) ClientCount() int {
 	s.Lock()
 	defer s.Unlock()
 
-	fmt.Printf("[MJPEG Stream] client count: %d\n", len(s.clients))
+	return len(s.clients)
 }
 
 // Broadcast - broadcasts the stream to clients
 func (s *Stream) Broadcast() {
 	go func() {
+		defer safety.Guard()
 		for {
-			image := <-s.Source
+			var image []byte
+			select {
+			case <-s.doneCh:
+				return
+			case image = <-s.Source:
+			}
+
 			s.Lock()
 			for _, updateClientCh := range s.clients {
 				select {
 				case updateClientCh <- image:
 				default:
+					frame.Drops.Drop(frame.StageStream)
 				}
 			}
 			s.Unlock()
@@ -85,6 +103,8 @@ func (s *Stream) HTTPHandler(res http.ResponseWriter, req *http.Request) {
 		select {
 		case <-clientClosed:
 			return
+		case <-s.doneCh:
+			return
 		case image := <-updateClientCh:
 			// JPEG headers
 			fmt.Fprintf(resBuffer, "%s\r\n", mjpegBoundary)
@@ -107,10 +127,22 @@ func (s *Stream) HTTPHandler(res http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// Close stops broadcasting and disconnects all clients
+func (s *Stream) Close() {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.doneCh)
+	}
+}
+
 // NewHandler create new HTTP handler
 func NewHandler(source chan []byte) *Stream {
 	stream := &Stream{
 		Source: source,
+		doneCh: make(chan struct{}),
 	}
 
 	stream.Broadcast()
@@ -0,0 +1,153 @@
+package params
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix of environment variables that override config file options,
+// ex. LASER_CAT_SERVO_X_MIN=70 sets -servo-x-min
+var EnvPrefix = "LASER_CAT_"
+
+// options that cannot be set in config file
+var configIgnored = map[string]bool{
+	"config":  true,
+	"profile": true,
+	"version": true,
+}
+
+// Config is a file with options (flag names without dashes),
+// profile options override default ones: {"default": {...}, "profiles": {"kitchen": {...}}}
+type Config struct {
+	Default  map[string]interface{}            `json:"default"`
+	Profiles map[string]map[string]interface{} `json:"profiles"`
+}
+
+// LoadConfig reads JSON config file
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("[Config] cannot read file '%s', error: %v", path, err)
+	}
+
+	c := &Config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("[Config] cannot parse file '%s', error: %v", path, err)
+	}
+
+	return c, nil
+}
+
+// Options returns default options merged with the profile ones, empty profile - default options only
+func (c *Config) Options(profile string) (map[string]interface{}, error) {
+	options := map[string]interface{}{}
+	for name, value := range c.Default {
+		options[name] = value
+	}
+
+	if profile != "" {
+		profileOptions, ok := c.Profiles[profile]
+		if !ok {
+			names := []string{}
+			for name := range c.Profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("[Config] unknown profile '%s', available: %s", profile, strings.Join(names, ", "))
+		}
+		for name, value := range profileOptions {
+			options[name] = value
+		}
+	}
+
+	return options, nil
+}
+
+// Apply sets profile options to flags, except skipped ones (ex. set on command line)
+func (c *Config) Apply(fs *flag.FlagSet, profile string, skip map[string]bool) error {
+	options, err := c.Options(profile)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if fs.Lookup(name) == nil || configIgnored[name] {
+			return fmt.Errorf("[Config] unknown option '%s'", name)
+		}
+		if skip[name] {
+			continue
+		}
+
+		value, err := FlagValue(options[name])
+		if err != nil {
+			return fmt.Errorf("[Config] option '%s' %v", name, err)
+		}
+
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("[Config] invalid option '%s' value '%s', error: %v", name, value, err)
+		}
+	}
+
+	return nil
+}
+
+// FlagValue converts JSON value to flag value string
+func FlagValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("must be a string, number or boolean")
+	}
+}
+
+// EnvName returns environment variable name for the flag, ex. "servo-x-min" -> "LASER_CAT_SERVO_X_MIN"
+func EnvName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// ApplyEnv sets flags from environment variables, except skipped ones (ex. set on command line),
+// returns names of set flags
+func ApplyEnv(fs *flag.FlagSet, skip map[string]bool) (map[string]bool, error) {
+	applied := map[string]bool{}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(EnvName(f.Name))
+		if !ok || skip[f.Name] || err != nil {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("[Config] invalid %s value '%s', error: %v", EnvName(f.Name), value, setErr)
+			return
+		}
+		applied[f.Name] = true
+	})
+
+	return applied, err
+}
+
+// CommandLineFlags returns names of flags set on command line
+func CommandLineFlags(fs *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
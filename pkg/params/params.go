@@ -20,6 +20,10 @@ var (
 
 // default application config
 var (
+	// config file with options and named profiles (JSON)
+	ConfigFile = ""
+	Profile    = ""
+
 	// servo X
 	ServoXPin                 = servo.RpiPwmPin12
 	ServoXMinAnglePulseLength = 74 // tested camera angle min (tested servo min: 49)  [right]
@@ -38,17 +42,84 @@ var (
 	CameraFPS       = 24
 
 	// motion detector
-	DetectorThreshold       = 7500 // color difference sensitivity
-	DetectorBlindSpotRadius = 10   // blind radius to prevent self-detection
+	Detector                = "diff" // "diff" - frame differencing, "background" - background subtraction
+	DetectorThreshold       = 7500   // color difference sensitivity
+	DetectorBlindSpotRadius = 10     // blind radius to prevent self-detection
+	DetectorLearningRate    = 0.05   // background model learning rate (for "background" detector)
+	DetectorMaskFile        = ""     // JSON file with include/exclude polygons and bitmap mask
+
+	// laser dot locator (finds actual dot position on the image)
+	LaserDotMinConfidence = 0.3 // found dot with less confidence is ignored, commanded position is used
+
+	// motion blobs (connected components of detected motion)
+	DetectorMinArea        = 9         // min blob bounding box area in pixels
+	DetectorMaxArea        = 0         // max blob bounding box area in pixels (0 - no limit)
+	DetectorMinAspectRatio = 0.0       // min blob width to height ratio (0 - no limit)
+	DetectorMaxAspectRatio = 0.0       // max blob width to height ratio (0 - no limit)
+	DetectorTarget         = "largest" // blob to run away from: "largest" or "nearest" (to the dot)
+
+	// motion tracker
+	TrackerMaxDistance = 0.2  // max distance to associate motion with a track, as percent of view area
+	TrackerTimeout     = 1000 // lost track expiration, * time.Millisecond
 
 	// run-away algorithm
 	RunAwayRadius             = 0.5   // as percent of view area width
 	AlwaysStayOnRunAwayRadius = false // run after motion if it's futher then run-away radius
 
+	// run-away strategy: "geometric" - from current position, "predictive" - from predicted path
+	RunAwayStrategy = "geometric"
+	RunAwayLatency  = 150 // servo latency for predictive run-away, * time.Millisecond
+
+	// laser behavior: "run-away", "lure", "wander" or "tease"
+	Behavior          = "run-away"
+	LureDistance      = 0.15 // lure: dot distance in front of the cat, as percent of view area width
+	TeaseNearDistance = 0.15 // tease: approach distance to dart away, as percent of view area width
+	TeaseDartDistance = 0.5  // tease: dart away distance, as percent of view area width
+	TeaseDartTime     = 2000 // tease: time to stay away after dart, * time.Millisecond
+	TeaseHideTime     = 1000 // tease: the dot disappears after dart, * time.Millisecond
+	WanderAmplitude   = 0.2  // wander: max move distance, as percent of view area width
+	WanderInterval    = 1500 // wander: time between moves, * time.Millisecond
+
+	// camera to laser calibration
+	CalibrationFile   = ""   // JSON file with image to servo mapping, empty - servo range matches camera frame
+	CalibrationGrid   = 4    // number of calibration points per axis
+	CalibrationSettle = 1000 // time for servos to reach calibration point, * time.Millisecond
+
+	// laser safety
+	KeepOutFile = "" // JSON file with zones in servo field coordinates the laser never goes to
+
+	// servos park position on exit
+	ParkX = 0.5
+	ParkY = 0.5
+
+	// laser emitter
+	LaserPin       = 0        // GPIO (BCM) pin the laser is connected to, 0 - not controlled (always on)
+	LaserPattern   = "steady" // "steady", "blink", "flicker" or "heartbeat"
+	LaserMaxOnTime = 0        // max continuous on time, * time.Millisecond, 0 - no limit
+	LaserCoolDown  = 1000     // forced off time after max on time, * time.Millisecond
+
 	// random dot movements
 	RandomMovementsAmplitude = 0.02 // as percent of view area width
 	RandomMovementsInterval  = 2    // * time.Second
 
-	// debug image stream
+	// HTTP server: debug image stream, telemetry, metrics and control endpoints
 	StreamPort = "8081"
+	HTTPAddr   = "" // bind address, all interfaces if empty
+
+	// session recording
+	RecordDir         = "recordings" // directory for recordings
+	RecordStreams     = "raw"        // comma separated: "raw" (camera frames) and/or "debug" (debug frames)
+	RecordFormat      = "mjpeg"      // "mjpeg" (concatenated JPEG images) or "avi" (MJPG video)
+	RecordSegment     = 300          // files rotation interval, * time.Second
+	RecordMaxFileSize = 64           // files rotation size, MB
+	RecordQuota       = 1024         // max size of all recordings, the oldest are removed, MB (0 - no limit)
+
+	// motion event clips
+	ClipsDir         = "clips" // directory for clips
+	ClipsMinArea     = 0.01    // motion area to start a clip, as percent of the image
+	ClipsMinDuration = 1000    // motion duration to start a clip, * time.Millisecond
+	ClipsPreRoll     = 5       // frames before the motion start, * time.Second
+	ClipsPostRoll    = 5       // frames after the motion end, * time.Second
+	ClipsMaxDuration = 60      // longer events are split into several clips, * time.Second
+	ClipsQuota       = 512     // max size of all clips, the oldest are removed, MB (0 - no limit)
 )
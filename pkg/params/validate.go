@@ -0,0 +1,159 @@
+package params
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// validator collects option errors, options are looked up by flag names
+type validator struct {
+	fs     *flag.FlagSet
+	errors []string
+}
+
+// lookup returns the flag, an undefined flag is an error (ex. a renamed option is still validated)
+func (v *validator) lookup(name string) (*flag.Flag, bool) {
+	f := v.fs.Lookup(name)
+	if f == nil {
+		v.errors = append(v.errors, fmt.Sprintf("-%s is not defined", name))
+		return nil, false
+	}
+	return f, true
+}
+
+func (v *validator) number(name string) (float64, bool) {
+	f, ok := v.lookup(name)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(f.Value.String(), 64)
+	if err != nil {
+		v.errors = append(v.errors, fmt.Sprintf("-%s must be a number", name))
+		return 0, false
+	}
+	return n, true
+}
+
+// between checks min <= value <= max
+func (v *validator) between(name string, min, max float64) {
+	if n, ok := v.number(name); ok && (n < min || n > max) {
+		v.errors = append(v.errors, fmt.Sprintf("-%s must be in range [%v-%v], got: %v", name, min, max, n))
+	}
+}
+
+// atLeast checks min <= value
+func (v *validator) atLeast(name string, min float64) {
+	if n, ok := v.number(name); ok && n < min {
+		v.errors = append(v.errors, fmt.Sprintf("-%s must be at least %v, got: %v", name, min, n))
+	}
+}
+
+// less checks value of the first option is less than value of the second one
+func (v *validator) less(lessName, greaterName string) {
+	a, okA := v.number(lessName)
+	b, okB := v.number(greaterName)
+	if okA && okB && a >= b {
+		v.errors = append(v.errors, fmt.Sprintf("-%s must be less than -%s, got: %v >= %v", lessName, greaterName, a, b))
+	}
+}
+
+// limit checks value of the first option does not exceed the limit option, limit 0 means no limit
+func (v *validator) limit(name, limitName string) {
+	a, okA := v.number(name)
+	b, okB := v.number(limitName)
+	if okA && okB && b != 0 && a > b {
+		v.errors = append(v.errors, fmt.Sprintf("-%s must not be greater than -%s, got: %v > %v", name, limitName, a, b))
+	}
+}
+
+// oneOf checks value is one of allowed values
+func (v *validator) oneOf(name string, values ...string) {
+	f, ok := v.lookup(name)
+	if !ok {
+		return
+	}
+	for _, value := range values {
+		if f.Value.String() == value {
+			return
+		}
+	}
+	v.errors = append(v.errors, fmt.Sprintf("-%s must be one of: %s, got: %s", name, strings.Join(values, ", "), f.Value))
+}
+
+// Validate checks option values and relations between them, all found errors are returned at once
+func Validate(fs *flag.FlagSet) error {
+	v := &validator{fs: fs}
+
+	// servo pulse lengths, per mille of PWM cycle
+	for _, name := range []string{"servo-x-min", "servo-x-max", "servo-y-min", "servo-y-max"} {
+		v.between(name, 1, 999)
+	}
+	v.less("servo-x-min", "servo-x-max")
+	v.less("servo-y-min", "servo-y-max")
+
+	// percent of view area
+	for _, name := range []string{
+		"run-away-radius",
+		"lure-distance",
+		"tease-near-distance",
+		"tease-dart-distance",
+		"wander-amplitude",
+		"tracker-max-distance",
+		"detector-learning-rate",
+		"laser-dot-min-confidence",
+		"park-x",
+		"park-y",
+		"ramdom-amplitude",
+		"clips-min-area",
+	} {
+		v.between(name, 0, 1)
+	}
+
+	v.atLeast("camera-fps", 1)
+	v.atLeast("camera-scale", 1)
+	v.atLeast("calibration-grid", 2)
+	v.atLeast("source-speed", 0)
+	v.atLeast("record-segment", 1)
+	v.atLeast("record-max-file-size", 1)
+	v.atLeast("record-quota", 0)
+	v.atLeast("clips-max-duration", 1)
+	v.atLeast("clips-quota", 0)
+
+	for _, name := range []string{
+		"calibration-settle",
+		"laser-pin",
+		"laser-max-on-time",
+		"laser-cool-down",
+		"run-away-latency",
+		"tease-dart-time",
+		"tease-hide-time",
+		"wander-interval",
+		"detector-threshold",
+		"detector-min-area",
+		"detector-max-area",
+		"detector-min-aspect-ratio",
+		"detector-max-aspect-ratio",
+		"tracker-timeout",
+		"detector-blind-spot-radius",
+		"random-interval",
+		"clips-min-duration",
+		"clips-pre-roll",
+		"clips-post-roll",
+	} {
+		v.atLeast(name, 0)
+	}
+	v.limit("detector-min-area", "detector-max-area")
+	v.limit("detector-min-aspect-ratio", "detector-max-aspect-ratio")
+
+	v.oneOf("detector-target", "largest", "nearest")
+	v.oneOf("run-away", "geometric", "predictive")
+	v.oneOf("record-format", "mjpeg", "avi")
+
+	if len(v.errors) > 0 {
+		return fmt.Errorf("[Config] invalid options:\n  %s", strings.Join(v.errors, "\n  "))
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package params
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testFlagSet defines only the given options
+func testFlagSet(options map[string]string) *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	for name, value := range options {
+		fs.String(name, value, "")
+	}
+	return fs
+}
+
+// valueErrors returns validation errors of defined options, errors of undefined options are skipped
+func valueErrors(err error) []string {
+	errors := []string{}
+	if err == nil {
+		return errors
+	}
+	for _, line := range strings.Split(err.Error(), "\n")[1:] {
+		line = strings.TrimSpace(line)
+		if !strings.HasSuffix(line, " is not defined") {
+			errors = append(errors, line)
+		}
+	}
+	return errors
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]string
+		errors  []string
+	}{
+		{"valid servo", map[string]string{"servo-x-min": "74", "servo-x-max": "97"}, []string{}},
+		{"servo x min equals max", map[string]string{"servo-x-min": "90", "servo-x-max": "90"},
+			[]string{"-servo-x-min must be less than -servo-x-max, got: 90 >= 90"}},
+		{"servo y min greater than max", map[string]string{"servo-y-min": "80", "servo-y-max": "75"},
+			[]string{"-servo-y-min must be less than -servo-y-max, got: 80 >= 75"}},
+		{"servo out of PWM cycle", map[string]string{"servo-x-min": "74", "servo-x-max": "1000"},
+			[]string{"-servo-x-max must be in range [1-999], got: 1000"}},
+		{"run-away radius below 0", map[string]string{"run-away-radius": "-0.1"},
+			[]string{"-run-away-radius must be in range [0-1], got: -0.1"}},
+		{"run-away radius above 1", map[string]string{"run-away-radius": "1.5"},
+			[]string{"-run-away-radius must be in range [0-1], got: 1.5"}},
+		{"percent bounds", map[string]string{"run-away-radius": "1", "park-x": "0"}, []string{}},
+		{"not a number", map[string]string{"park-y": "top"}, []string{"-park-y must be a number"}},
+		{"min area above max area", map[string]string{"detector-min-area": "500", "detector-max-area": "100"},
+			[]string{"-detector-min-area must not be greater than -detector-max-area, got: 500 > 100"}},
+		{"min area without max area", map[string]string{"detector-min-area": "500", "detector-max-area": "0"}, []string{}},
+		{"negative time", map[string]string{"clips-pre-roll": "-1"},
+			[]string{"-clips-pre-roll must be at least 0, got: -1"}},
+		{"known value", map[string]string{"record-format": "avi"}, []string{}},
+		{"unknown value", map[string]string{"record-format": "mp4"},
+			[]string{"-record-format must be one of: mjpeg, avi, got: mp4"}},
+		{"all errors at once", map[string]string{"camera-fps": "0", "calibration-grid": "1"},
+			[]string{"-camera-fps must be at least 1, got: 0", "-calibration-grid must be at least 2, got: 1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errors := valueErrors(Validate(testFlagSet(test.options)))
+			if !reflect.DeepEqual(errors, test.errors) {
+				t.Errorf("errors: %q, expected: %q", errors, test.errors)
+			}
+		})
+	}
+}
+
+func TestValidateUndefinedOption(t *testing.T) {
+	err := Validate(testFlagSet(map[string]string{"run-away-radius": "0.5"}))
+	if err == nil {
+		t.Fatal("undefined options are not reported")
+	}
+	if strings.Contains(err.Error(), "-run-away-radius is not defined") {
+		t.Errorf("defined option -run-away-radius is reported: %v", err)
+	}
+	for _, name := range []string{"detector-target", "servo-x-min"} {
+		if !strings.Contains(err.Error(), "-"+name+" is not defined") {
+			t.Errorf("undefined option -%s is not reported: %v", name, err)
+		}
+	}
+}
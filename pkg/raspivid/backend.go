@@ -0,0 +1,145 @@
+package raspivid
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// Backend builds a command line of a camera program that writes MJPEG stream to stdout
+type Backend interface {
+	// Binary returns the program to run
+	Binary() string
+
+	// Args returns the program arguments for the stream settings
+	Args(s *ImageStream) []string
+}
+
+// backend names
+const (
+	BackendAuto      = "auto"
+	BackendRaspivid  = "raspivid"
+	BackendLibcamera = "libcamera"
+)
+
+// libcamera based programs, the new name goes first
+var libcameraBinaries = []string{"rpicam-vid", "libcamera-vid"}
+
+// Raspivid is a backend for legacy camera stack `raspivid` program
+type Raspivid struct {
+	Path string // program path, "raspivid" if empty
+}
+
+// Binary returns the program to run
+func (b *Raspivid) Binary() string {
+	if b.Path != "" {
+		return b.Path
+	}
+	return "raspivid"
+}
+
+// Args returns the program arguments for the stream settings
+func (b *Raspivid) Args(s *ImageStream) []string {
+	options := []string{
+		"-o", "-", // to write to stdout
+		"--codec", "MJPEG", // MJPEG codec for Motion JPEG
+		"--width", fmt.Sprint(s.Width), // set image width <size>
+		"--height", fmt.Sprint(s.Height), // set image height <size>
+		"--framerate", fmt.Sprint(s.FPS), // specify the frames per second to record (FPS)
+		"--nopreview",    // do not display a preview window
+		"--timeout", "0", // time (in ms) to capture for. If not specified, set to 5s. Zero to disable
+		"--flush", // flush buffers in order to decrease latency
+	}
+
+	if s.FlipH {
+		options = append(options, "--hflip") // set horizontal flip
+	}
+	if s.FlipV {
+		options = append(options, "--vflip") // set vertical flip
+	}
+
+	return options
+}
+
+// Libcamera is a backend for `rpicam-vid` (or older `libcamera-vid`) program
+type Libcamera struct {
+	Path string // program path, the first of "rpicam-vid" and "libcamera-vid" found in $PATH if empty
+}
+
+// Binary returns the program to run
+func (b *Libcamera) Binary() string {
+	if b.Path != "" {
+		return b.Path
+	}
+	for _, binary := range libcameraBinaries {
+		if _, err := exec.LookPath(binary); err == nil {
+			return binary
+		}
+	}
+	return libcameraBinaries[0]
+}
+
+// Args returns the program arguments for the stream settings
+func (b *Libcamera) Args(s *ImageStream) []string {
+	options := []string{
+		"--output", "-", // to write to stdout
+		"--codec", "mjpeg", // MJPEG codec for Motion JPEG
+		"--width", fmt.Sprint(s.Width), // set image width <size>
+		"--height", fmt.Sprint(s.Height), // set image height <size>
+		"--framerate", fmt.Sprint(s.FPS), // specify the frames per second to record (FPS)
+		"--nopreview",    // do not display a preview window
+		"--timeout", "0", // time (in ms) to capture for. Zero to disable
+		"--flush", // flush output files immediately
+	}
+
+	if s.FlipH {
+		options = append(options, "--hflip") // set horizontal flip
+	}
+	if s.FlipV {
+		options = append(options, "--vflip") // set vertical flip
+	}
+
+	return options
+}
+
+// DetectBackend probes $PATH for camera programs,
+// `raspivid` is preferred to keep legacy setups unchanged
+func DetectBackend() (Backend, error) {
+	if _, err := exec.LookPath("raspivid"); err == nil {
+		return &Raspivid{}, nil
+	}
+
+	for _, binary := range libcameraBinaries {
+		if _, err := exec.LookPath(binary); err == nil {
+			return &Libcamera{Path: binary}, nil
+		}
+	}
+
+	return nil, fmt.Errorf(
+		"[raspivid ImageStream] no camera program found in $PATH, tried: raspivid, %v",
+		libcameraBinaries,
+	)
+}
+
+// NewBackend creates a backend by name: "auto", "raspivid" or "libcamera",
+// path overrides the program to run (for example a stub script for testing)
+func NewBackend(name, path string) (Backend, error) {
+	switch name {
+	case BackendRaspivid:
+		return &Raspivid{Path: path}, nil
+	case BackendLibcamera:
+		return &Libcamera{Path: path}, nil
+	case BackendAuto, "":
+		if path != "" {
+			return nil, fmt.Errorf("[raspivid ImageStream] backend must be set to use program path '%s'", path)
+		}
+		return DetectBackend()
+	default:
+		return nil, fmt.Errorf(
+			"[raspivid ImageStream] unknown backend '%s', use: %s, %s or %s",
+			name,
+			BackendAuto,
+			BackendRaspivid,
+			BackendLibcamera,
+		)
+	}
+}
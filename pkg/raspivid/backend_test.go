@@ -0,0 +1,193 @@
+package raspivid
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testJPEG encodes a small image
+func testJPEG(t *testing.T) []byte {
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 16, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeStubProgram writes a camera program stub to the directory,
+// the stub saves its arguments to "args" file and writes "frame.jpg" to stdout in a loop
+func writeStubProgram(t *testing.T, dir, name string) {
+	script := fmt.Sprintf(`#!/bin/sh
+for arg in "$@"; do echo "$arg"; done > %[1]s/args
+while :; do /bin/cat %[1]s/frame.jpg; /bin/sleep 0.02; done
+`, dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackendArgs(t *testing.T) {
+	t.Setenv("PATH", "") // no camera programs are installed
+	s := &ImageStream{Width: 128, Height: 96, FPS: 15, FlipV: true}
+
+	tests := []struct {
+		name    string
+		backend Backend
+		binary  string
+		args    string
+	}{
+		{
+			"raspivid",
+			&Raspivid{},
+			"raspivid",
+			"-o - --codec MJPEG --width 128 --height 96 --framerate 15 --nopreview --timeout 0 --flush --vflip",
+		},
+		{
+			"libcamera",
+			&Libcamera{},
+			"rpicam-vid",
+			"--output - --codec mjpeg --width 128 --height 96 --framerate 15 --nopreview --timeout 0 --flush --vflip",
+		},
+		{
+			"libcamera with path",
+			&Libcamera{Path: "/opt/camera/libcamera-vid"},
+			"/opt/camera/libcamera-vid",
+			"--output - --codec mjpeg --width 128 --height 96 --framerate 15 --nopreview --timeout 0 --flush --vflip",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if binary := test.backend.Binary(); binary != test.binary {
+				t.Errorf("binary: %s, expected: %s", binary, test.binary)
+			}
+			if args := strings.Join(test.backend.Args(s), " "); args != test.args {
+				t.Errorf("args: %s, expected: %s", args, test.args)
+			}
+		})
+	}
+}
+
+func TestDetectBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raspivid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Setenv("PATH", dir)
+
+	if _, err := DetectBackend(); err == nil {
+		t.Error("backend is detected in empty $PATH")
+	}
+
+	writeStubProgram(t, dir, "rpicam-vid")
+	backend, err := DetectBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := backend.(*Libcamera); !ok || b.Binary() != "rpicam-vid" {
+		t.Errorf("backend: %#v, expected: rpicam-vid", backend)
+	}
+
+	// older systems ship only libcamera-vid
+	if err := os.Remove(filepath.Join(dir, "rpicam-vid")); err != nil {
+		t.Fatal(err)
+	}
+	writeStubProgram(t, dir, "libcamera-vid")
+	for _, name := range []string{BackendAuto, BackendLibcamera} {
+		backend, err := NewBackend(name, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, ok := backend.(*Libcamera); !ok || b.Binary() != "libcamera-vid" {
+			t.Errorf("%s backend: %#v, expected: libcamera-vid", name, backend)
+		}
+	}
+
+	// legacy program is preferred
+	writeStubProgram(t, dir, "raspivid")
+	backend, err = NewBackend(BackendAuto, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := backend.(*Raspivid); !ok {
+		t.Errorf("backend: %#v, expected: raspivid", backend)
+	}
+}
+
+func TestLibcameraStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "raspivid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	picture := testJPEG(t)
+	if err := ioutil.WriteFile(filepath.Join(dir, "frame.jpg"), picture, 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeStubProgram(t, dir, "rpicam-vid")
+	t.Setenv("PATH", dir)
+
+	backend, err := NewBackend(BackendLibcamera, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ImageStream{Backend: backend, FlipH: true, Options: []string{"--denoise", "off"}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := s.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case f := <-ch:
+			if !bytes.Equal(f.Data, picture) {
+				t.Fatalf("frame %d differs from the written image", i)
+			}
+			if f.Source != "rpicam-vid" {
+				t.Errorf("frame source: %s", f.Source)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no frames from the camera program")
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := strings.Split(strings.TrimSpace(string(data)), "\n")
+	expected := append(backend.Args(s), "--denoise", "off")
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("args: %v, expected: %v", args, expected)
+	}
+
+	// the channel is closed after the program is stopped
+	cancel()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("stream is not stopped")
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package raspivid
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventType is a type of camera process event
+type EventType string
+
+// camera process events
+const (
+	EventStarted    EventType = "started"    // process is started
+	EventExited     EventType = "exited"     // process exited or failed to start
+	EventRestarting EventType = "restarting" // process is going to be restarted after backoff
+	EventStopped    EventType = "stopped"    // stream is stopped by context cancellation
+)
+
+// Event describes camera process state change
+type Event struct {
+	Type     EventType
+	Time     time.Time
+	PID      int
+	ExitCode int           // -1 if process was killed by signal or didn't start
+	Err      error         // exit or start error
+	Stderr   string        // the last lines of process stderr output
+	Restarts int           // number of restarts since stream start
+	Backoff  time.Duration // delay before the next restart
+}
+
+func (e Event) String() string {
+	switch e.Type {
+	case EventStarted:
+		return fmt.Sprintf("started, pid: %d, restarts: %d", e.PID, e.Restarts)
+	case EventExited:
+		return fmt.Sprintf("exited, pid: %d, code: %d, error: %v, stderr: %q", e.PID, e.ExitCode, e.Err, e.Stderr)
+	case EventRestarting:
+		return fmt.Sprintf("restarting in %s, restarts: %d", e.Backoff, e.Restarts)
+	default:
+		return string(e.Type)
+	}
+}
+
+// tailBuffer keeps the last written bytes
+type tailBuffer struct {
+	sync.Mutex
+	size int
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.data = append(b.data, p...)
+	if len(b.data) > b.size {
+		b.data = b.data[len(b.data)-b.size:]
+	}
+
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+
+	return string(b.data)
+}
+
+func (b *tailBuffer) Reset() {
+	b.Lock()
+	defer b.Unlock()
+
+	b.data = nil
+}
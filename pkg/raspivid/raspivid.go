@@ -1,12 +1,19 @@
 package raspivid
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/frame"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/mjpeg"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/safety"
 )
 
 var (
@@ -14,14 +21,31 @@ var (
 	defaultWidth  = 4 * 32 // the horizontal resolution is rounded up to the nearest multiple of 32 pixels
 	defaultHeight = 3 * 32 // the vertical resolution is rounded up to the nearest multiple of 16 pixels
 	defaultFPS    = 15
+
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = time.Minute
+	killTimeout       = 3 * time.Second // time to wait after SIGTERM before killing the process
+	stderrTailSize    = 1024
 )
 
-// ImageStream runs `rapsivid` program and returns a stream of pictures (as []byte)
+// ImageStream runs camera program (`rapsivid` by default) and returns a stream of pictures (as []byte)
 type ImageStream struct {
 	Width   int
 	Height  int
 	FPS     int
-	Options []string // any additional options to pass to `rapsivid`
+	FlipH   bool
+	FlipV   bool
+	Options []string // any additional options to pass to the camera program
+
+	// Backend builds camera program command line, Raspivid is used if nil
+	Backend Backend
+
+	// restart backoff: starts with MinBackoff and doubles after each failure up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Events is an optional channel of process events (events are dropped if nobody reads)
+	Events chan Event
 }
 
 func (s *ImageStream) makeOptions() []string {
@@ -34,18 +58,12 @@ func (s *ImageStream) makeOptions() []string {
 	if s.Height == 0 {
 		s.Height = defaultHeight
 	}
-
-	options := []string{
-		"-o", "-", // to write to stdout
-		"--codec", "MJPEG", // MJPEG codec for Motion JPEG
-		"--width", fmt.Sprint(s.Width), // set image width <size>
-		"--height", fmt.Sprint(s.Height), // set image height <size>
-		"--framerate", fmt.Sprint(s.FPS), // specify the frames per second to record (FPS)
-		"--nopreview",    // do not display a preview window
-		"--timeout", "0", // time (in ms) to capture for. If not specified, set to 5s. Zero to disable
-		"--flush", // flush buffers in order to decrease latency
+	if s.Backend == nil {
+		s.Backend = &Raspivid{}
 	}
 
+	options := s.Backend.Args(s)
+
 	if len(s.Options) > 0 {
 		options = append(options, s.Options...)
 	}
@@ -53,81 +71,202 @@ func (s *ImageStream) makeOptions() []string {
 	return options
 }
 
-func (s *ImageStream) parseRaspividOutput(output io.ReadCloser, ch chan []byte) {
-	// JPEG SOI marker-|----------|
-	var marker = []byte{0xFF, 0xD8, 0xFF, 0xDB, 0x00, 0x84, 0x00}
-	var markerLength = len(marker)
-	var imagesBuffer = new(bytes.Buffer)
+func (s *ImageStream) parseRaspividOutput(ctx context.Context, output io.Reader, seq *frame.Sequence, ch chan frame.Frame) {
+	reader := mjpeg.NewReader(output)
 
 	for {
-		// read raspivid output by chunks
-		var readBuffer = make([]byte, 4096) //TODO try other values
-		n, err := output.Read(readBuffer)   // check n like there: https://golang.org/doc/effective_go.html#defer
-		if err != nil {
+		imageBytes, err := reader.ReadFrame()
+		if frameErr, ok := err.(*mjpeg.FrameError); ok {
+			fmt.Println(frameErr)
+			frame.Drops.Drop(frame.StageCorrupt)
+			continue
+		} else if err != nil {
 			fmt.Printf("[raspivid ImageStream] read output error: %s\n", err)
-			close(ch)
-			break
+			return
 		}
 
-		for i := 0; i < n; i++ {
-			imagesBuffer.WriteByte(readBuffer[i])
-			// look for the marker at the end of buffer (ignore the first found marker)
-			if bytes.HasSuffix(imagesBuffer.Bytes(), marker) && imagesBuffer.Len() > markerLength {
-				// cut off the marker from the end
-				imageLength := imagesBuffer.Len() - markerLength
-				imageBytes := make([]byte, imageLength)
-				copy(imageBytes, imagesBuffer.Bytes()[:imageLength])
-
-				// try to send new found image to the channel
-				select {
-				case ch <- imageBytes:
-				default:
-				}
-
-				// reset image buffer and add marker to the beginning (that was cut above)
-				imagesBuffer.Reset()
-				imagesBuffer.Write(marker)
-			}
+		// try to send new found image to the channel
+		select {
+		case ch <- seq.Next(imageBytes):
+		case <-ctx.Done():
+			return
+		default:
+			frame.Drops.Drop(frame.StageCapture)
 		}
 	}
 }
 
-// Start returns a channel of images
-func (s *ImageStream) Start() (chan []byte, error) {
-	fmt.Printf("[raspivid ImageStream] start...\n")
+func (s *ImageStream) emit(event Event) {
+	event.Time = time.Now()
+	fmt.Printf("[raspivid ImageStream] %s\n", event)
 
-	options := s.makeOptions() //TODO validate options
+	if s.Events != nil {
+		select {
+		case s.Events <- event:
+		default:
+		}
+	}
+}
 
-	cmd := exec.Command("raspivid", options...) //TODO use context to cancel command
-	fmt.Printf("[raspivid ImageStream] command to run: raspivid %s\n", strings.Join(options, " "))
+// startCommand starts camera process with stdout piped
+func (s *ImageStream) startCommand(options []string, stderr *tailBuffer) (*exec.Cmd, io.Reader, error) {
+	cmd := exec.Command(s.Backend.Binary(), options...)
 
-	// log errors to stdout
-	cmd.Stderr = os.Stdout
+	// log errors to stdout and keep the last lines for events
+	stderr.Reset()
+	cmd.Stderr = io.MultiWriter(os.Stdout, stderr)
 
-	// pipe raspivid output to parser
+	// pipe camera output to parser
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		fmt.Printf("[raspivid ImageStream] piping error: %s\n", err)
-		return nil, err
+		return nil, nil, fmt.Errorf("piping error: %s", err)
 	}
 
 	err = cmd.Start()
 	if err != nil {
-		fmt.Printf("[raspivid ImageStream] command starting error: %s\n", err)
+		return nil, nil, fmt.Errorf("command starting error: %s", err)
+	}
+
+	return cmd, stdout, nil
+}
+
+// run reads frames from started process until it exits or context is cancelled,
+// returns process exit event
+func (s *ImageStream) run(
+	ctx context.Context,
+	cmd *exec.Cmd,
+	stdout io.Reader,
+	stderr *tailBuffer,
+	seq *frame.Sequence,
+	ch chan frame.Frame,
+) Event {
+	exitedCh := make(chan struct{})
+	defer close(exitedCh)
+
+	// terminate the process on context cancellation
+	go func() {
+		defer safety.Guard()
+		select {
+		case <-exitedCh:
+		case <-ctx.Done():
+			cmd.Process.Signal(syscall.SIGTERM)
+			select {
+			case <-exitedCh:
+			case <-time.After(killTimeout):
+				cmd.Process.Kill()
+			}
+		}
+	}()
+
+	s.parseRaspividOutput(ctx, stdout, seq, ch)
+
+	// Wait closes the pipe after seeing the command exit,
+	// it is called after all reads from the pipe have completed
+	err := cmd.Wait()
+
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+			exitCode = status.ExitStatus()
+		}
+	}
+
+	return Event{
+		Type:     EventExited,
+		PID:      cmd.Process.Pid,
+		ExitCode: exitCode,
+		Err:      err,
+		Stderr:   stderr.String(),
+	}
+}
+
+// supervise restarts the process with exponential backoff until context is cancelled
+func (s *ImageStream) supervise(
+	ctx context.Context,
+	options []string,
+	cmd *exec.Cmd,
+	stdout io.Reader,
+	stderr *tailBuffer,
+	ch chan frame.Frame,
+) {
+	defer safety.Guard()
+	defer close(ch)
+
+	// frames numbering continues after restarts
+	seq := &frame.Sequence{Source: filepath.Base(s.Backend.Binary())}
+
+	backoff := s.MinBackoff
+	restarts := 0
+
+	for {
+		if cmd != nil {
+			startTime := time.Now()
+			s.emit(Event{Type: EventStarted, PID: cmd.Process.Pid, Restarts: restarts})
+			event := s.run(ctx, cmd, stdout, stderr, seq, ch)
+			event.Restarts = restarts
+			s.emit(event)
+
+			// process was running long enough, so it's a new failure
+			if time.Since(startTime) > s.MaxBackoff {
+				backoff = s.MinBackoff
+			}
+		}
+
+		if ctx.Err() != nil {
+			s.emit(Event{Type: EventStopped, Restarts: restarts})
+			return
+		}
+
+		s.emit(Event{Type: EventRestarting, Restarts: restarts, Backoff: backoff})
+		select {
+		case <-ctx.Done():
+			s.emit(Event{Type: EventStopped, Restarts: restarts})
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+		restarts++
+
+		var err error
+		cmd, stdout, err = s.startCommand(options, stderr)
+		if err != nil {
+			s.emit(Event{Type: EventExited, ExitCode: -1, Err: err, Restarts: restarts})
+		}
+	}
+}
+
+// Start returns a channel of images, camera program is restarted if it exits,
+// the channel is closed and the program is terminated when context is cancelled
+func (s *ImageStream) Start(ctx context.Context) (chan frame.Frame, error) {
+	fmt.Printf("[raspivid ImageStream] start...\n")
+
+	options := s.makeOptions() //TODO validate options
+
+	if s.MinBackoff == 0 {
+		s.MinBackoff = defaultMinBackoff
+	}
+	if s.MaxBackoff == 0 {
+		s.MaxBackoff = defaultMaxBackoff
+	}
+
+	fmt.Printf("[raspivid ImageStream] command to run: %s %s\n", s.Backend.Binary(), strings.Join(options, " "))
+
+	stderr := &tailBuffer{size: stderrTailSize}
+	cmd, stdout, err := s.startCommand(options, stderr)
+	if err != nil {
+		fmt.Printf("[raspivid ImageStream] %s\n", err)
 		return nil, err
 	}
 
 	// channel for images
-	ch := make(chan []byte)
-
-	// loop packs images from raspivid stdout and sends them to the channel
-	go s.parseRaspividOutput(stdout, ch)
+	ch := make(chan frame.Frame)
 
-	//TODO cmd.Wait()
-	// Wait will close the pipe after seeing the command exit,
-	// so most callers need not close the pipe themselves;
-	// however, an implication is that it is incorrect to call
-	/// Wait before all reads from the pipe have completed.
+	// loop packs images from camera program stdout and sends them to the channel
+	go s.supervise(ctx, options, cmd, stdout, stderr, ch)
 
 	return ch, nil
 }
@@ -0,0 +1,487 @@
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/frame"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/mjpeg"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/safety"
+)
+
+var (
+	defaultClipMaxDuration = time.Minute
+	clipQueueSize          = 64 // in addition to pre-roll frames
+	clipPrefix             = "clip-"
+)
+
+// clip writer operations
+const (
+	clipOpen = iota
+	clipWrite
+	clipClose
+)
+
+// clipEntry is a clip writer operation
+type clipEntry struct {
+	op   int
+	info ClipInfo // clipOpen and clipClose
+	data []byte   // clipWrite
+}
+
+// ClipInfo is clip metadata, it's saved next to the clip video as <name>.json
+type ClipInfo struct {
+	Name         string     `json:"name"`
+	Video        string     `json:"video"`         // video file name
+	Start        time.Time  `json:"start"`         // the first frame time (pre-roll)
+	Trigger      time.Time  `json:"trigger"`       // motion start time
+	End          *time.Time `json:"end,omitempty"` // the last frame time, empty while the clip is recorded (estimated for interrupted clips)
+	Frames       int        `json:"frames"`
+	PeakActivity float64    `json:"peak_activity"` // max motion area, as percent of the image
+	PeakTime     time.Time  `json:"peak_time"`
+}
+
+// EventRecorder writes clips of motion events: the last frames are kept in a ring buffer, a clip starts
+// when motion area is above MinArea for MinDuration (with PreRoll frames before the motion) and ends
+// when there is no motion for PostRoll. Clips are AVI (MJPG) files with JSON metadata:
+// clip-<motion start time>.avi and .json. Frames are written in background, and dropped if the disk is too slow.
+type EventRecorder struct {
+	Dir         string
+	Width       int           // frame size
+	Height      int           // frame size
+	FPS         int           // frame rate
+	MinArea     float64       // motion area to start a clip, as percent of the image
+	MinDuration time.Duration // motion duration to start a clip
+	PreRoll     time.Duration // frames before the motion start, 0 - none
+	PostRoll    time.Duration // frames after the motion end, 0 - none
+	MaxDuration time.Duration // longer events are split into several clips
+	Quota       int64         // max size of all clips in the directory, the oldest are removed, 0 - no limit
+
+	sync.Mutex
+	started     bool
+	buffer      *ringBuffer
+	motionSince time.Time // zero if there is no motion
+	lastMotion  time.Time
+	clip        *ClipInfo   // open clip
+	clips       uint64      // started clips
+	pending     []clipEntry // clip open and close operations wait here if the queue is full
+	queue       chan clipEntry
+	doneCh      chan struct{}
+}
+
+func (r *EventRecorder) setDefaults() {
+	if r.MaxDuration == 0 {
+		r.MaxDuration = defaultClipMaxDuration
+	}
+}
+
+// Validate checks video format
+func (r *EventRecorder) Validate() error {
+	if r.Width <= 0 || r.Height <= 0 || r.FPS <= 0 {
+		return fmt.Errorf("[Event Recorder] invalid video format: %dx%d@%d", r.Width, r.Height, r.FPS)
+	}
+	return nil
+}
+
+// Start starts watching frames for motion events
+func (r *EventRecorder) Start() error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	r.Stop()
+
+	// recovery of large files takes time, frames are not blocked meanwhile
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return fmt.Errorf("[Event Recorder] cannot create directory '%s', error: %v", r.Dir, err)
+	}
+	recoverVideos(r.Dir, clipPrefix, func(video string, frames int) {
+		if err := recoverClipInfo(r.Dir, video, frames, r.FPS); err != nil {
+			fmt.Println(err)
+		}
+	})
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.setDefaults()
+
+	// frames before the motion start and while motion lasts to start a clip
+	bufferSize := int((r.PreRoll+r.MinDuration).Seconds()*float64(r.FPS)) + 1
+
+	r.started = true
+	r.buffer = newRingBuffer(bufferSize)
+	r.motionSince = time.Time{}
+	r.clip = nil
+	r.pending = nil
+	r.queue = make(chan clipEntry, bufferSize+clipQueueSize)
+	r.doneCh = make(chan struct{})
+	go r.run(r.queue, r.doneCh)
+
+	fmt.Printf(
+		"[Event Recorder] start: %s, min area: %.3f, min duration: %s, pre-roll: %s, post-roll: %s\n",
+		r.Dir,
+		r.MinArea,
+		r.MinDuration,
+		r.PreRoll,
+		r.PostRoll,
+	)
+
+	return nil
+}
+
+// Stop finishes the open clip, all queued frames are written
+func (r *EventRecorder) Stop() {
+	r.Lock()
+	if r.clip != nil {
+		r.closeClip()
+	}
+	queue := r.queue
+	doneCh := r.doneCh
+	pending := r.pending
+	r.started = false
+	r.buffer = nil
+	r.pending = nil
+	r.queue = nil
+	r.Unlock()
+
+	if queue == nil {
+		return
+	}
+
+	for _, e := range pending {
+		queue <- e
+	}
+	close(queue)
+	<-doneCh
+
+	fmt.Println("[Event Recorder] stop")
+}
+
+// Clips returns number of started clips
+func (r *EventRecorder) Clips() uint64 {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.clips
+}
+
+// IsRecording returns true if a clip is being recorded
+func (r *EventRecorder) IsRecording() bool {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.clip != nil
+}
+
+// WriteFrame adds camera frame with motion activity (motion area, as percent of the image)
+func (r *EventRecorder) WriteFrame(f frame.Frame, activity float64) {
+	r.Lock()
+	defer r.Unlock()
+
+	if !r.started {
+		return
+	}
+	r.flush()
+
+	// motion must last without breaks to start a clip
+	if activity > 0 && activity >= r.MinArea {
+		if r.motionSince.IsZero() {
+			r.motionSince = f.Time
+		}
+		r.lastMotion = f.Time
+	} else {
+		r.motionSince = time.Time{}
+	}
+
+	cf := clipFrame{Frame: f, activity: activity}
+
+	if r.clip == nil {
+		r.buffer.push(cf)
+		if !r.motionSince.IsZero() && f.Time.Sub(r.motionSince) >= r.MinDuration {
+			r.openClip()
+		}
+		return
+	}
+
+	r.writeFrame(cf)
+
+	if f.Time.Sub(r.lastMotion) >= r.PostRoll {
+		r.closeClip()
+	} else if f.Time.Sub(r.clip.Start) >= r.MaxDuration {
+		r.closeClip()
+		// the next clip starts with the following frames if motion still lasts
+		r.motionSince = time.Time{}
+	}
+}
+
+// openClip starts a clip with buffered frames
+func (r *EventRecorder) openClip() {
+	frames := r.buffer.since(r.motionSince.Add(-r.PreRoll))
+	r.buffer.reset()
+
+	name := fmt.Sprintf(
+		"%s%s-%03d",
+		clipPrefix,
+		r.motionSince.Format("20060102-150405"),
+		r.motionSince.Nanosecond()/int(time.Millisecond),
+	)
+	r.clip = &ClipInfo{
+		Name:    name,
+		Video:   name + ".avi",
+		Start:   frames[0].Time,
+		Trigger: r.motionSince,
+	}
+	r.clips++
+
+	r.queueOp(clipEntry{op: clipOpen, info: *r.clip})
+	for _, f := range frames {
+		r.writeFrame(f)
+	}
+
+	fmt.Printf("[Event Recorder] clip %s is started, pre-roll: %s\n", name, r.clip.Trigger.Sub(r.clip.Start))
+}
+
+// writeFrame queues the frame of the open clip, the frame is dropped if the queue is full
+// or clip operations are pending (they go first)
+func (r *EventRecorder) writeFrame(f clipFrame) {
+	if !r.flush() {
+		frame.Drops.Drop(frame.StageClip)
+	} else {
+		select {
+		case r.queue <- clipEntry{op: clipWrite, data: f.Data}:
+		default:
+			frame.Drops.Drop(frame.StageClip)
+		}
+	}
+
+	end := f.Time
+	r.clip.End = &end
+	r.clip.Frames++
+	if f.activity > r.clip.PeakActivity {
+		r.clip.PeakActivity = f.activity
+		r.clip.PeakTime = f.Time
+	}
+}
+
+// closeClip finishes the open clip
+func (r *EventRecorder) closeClip() {
+	r.queueOp(clipEntry{op: clipClose, info: *r.clip})
+	r.clip = nil
+}
+
+// queueOp queues clip open or close operation, the frame loop is never blocked by the writer:
+// operations are kept in order and wait if the queue is full
+func (r *EventRecorder) queueOp(e clipEntry) {
+	r.pending = append(r.pending, e)
+	r.flush()
+}
+
+// flush queues pending operations while there is room, returns true if there are no pending operations
+func (r *EventRecorder) flush() bool {
+	for len(r.pending) > 0 {
+		select {
+		case r.queue <- r.pending[0]:
+			r.pending = r.pending[1:]
+		default:
+			return false
+		}
+	}
+	r.pending = nil
+	return true
+}
+
+// run writes queued clips until the queue is closed
+func (r *EventRecorder) run(queue chan clipEntry, doneCh chan struct{}) {
+	defer safety.Guard()
+	defer close(doneCh)
+
+	var file *os.File
+	var video *mjpeg.AVIWriter
+
+	closeVideo := func() {
+		if err := video.Close(); err != nil {
+			fmt.Printf("[Event Recorder] cannot finalize video '%s', error: %v\n", file.Name(), err)
+		}
+		if err := file.Close(); err != nil {
+			fmt.Printf("[Event Recorder] cannot close file '%s', error: %v\n", file.Name(), err)
+		}
+		file = nil
+		video = nil
+	}
+
+	for e := range queue {
+		switch e.op {
+		case clipOpen:
+			if video != nil {
+				closeVideo()
+			}
+			enforceQuota(r.Dir, clipPrefix, r.Quota, "")
+
+			var err error
+			path := filepath.Join(r.Dir, e.info.Video)
+			file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+			if err != nil {
+				fmt.Printf("[Event Recorder] cannot create file '%s', error: %v\n", path, err)
+				continue
+			}
+			video, err = mjpeg.NewAVIWriter(file, r.Width, r.Height, r.FPS)
+			if err != nil {
+				fmt.Printf("[Event Recorder] cannot create video '%s', error: %v\n", path, err)
+				file.Close()
+				file = nil
+				continue
+			}
+			if err := writeClipInfo(r.Dir, e.info); err != nil {
+				fmt.Println(err)
+			}
+
+		case clipWrite:
+			if video == nil {
+				continue
+			}
+			if err := video.WriteFrame(e.data); err != nil {
+				fmt.Printf("[Event Recorder] cannot write video '%s', error: %v\n", file.Name(), err)
+				closeVideo()
+			}
+
+		case clipClose:
+			if video == nil {
+				continue
+			}
+			e.info.Frames = video.Frames()
+			closeVideo()
+			if err := writeClipInfo(r.Dir, e.info); err != nil {
+				fmt.Println(err)
+			}
+			fmt.Printf(
+				"[Event Recorder] clip %s is saved: %d frames, %s, peak activity: %.3f\n",
+				e.info.Name,
+				e.info.Frames,
+				e.info.End.Sub(e.info.Start),
+				e.info.PeakActivity,
+			)
+		}
+	}
+
+	if video != nil {
+		closeVideo()
+	}
+}
+
+// writeClipInfo saves clip metadata, the file is replaced at once
+func writeClipInfo(dir string, info ClipInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("[Event Recorder] cannot encode clip metadata, error: %v", err)
+	}
+
+	path := filepath.Join(dir, info.Name+".json")
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("[Event Recorder] cannot write file '%s', error: %v", path, err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("[Event Recorder] cannot write file '%s', error: %v", path, err)
+	}
+
+	return nil
+}
+
+// recoverClipInfo updates metadata of interrupted clip with frames of the recovered video,
+// the end time is estimated by the frame rate
+func recoverClipInfo(dir, video string, frames, fps int) error {
+	path := filepath.Join(dir, strings.TrimSuffix(video, filepath.Ext(video))+".json")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("[Event Recorder] cannot read file '%s', error: %v", path, err)
+	}
+	var info ClipInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return fmt.Errorf("[Event Recorder] cannot parse file '%s', error: %v", path, err)
+	}
+
+	info.Frames = frames
+	end := info.Start
+	if frames > 1 && fps > 0 {
+		end = end.Add(time.Duration(frames-1) * time.Second / time.Duration(fps))
+	}
+	info.End = &end
+
+	return writeClipInfo(dir, info)
+}
+
+// ListClips returns metadata of clips in the directory, the oldest first
+func ListClips(dir string) ([]ClipInfo, error) {
+	clips := []ClipInfo{}
+
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) { // no clips yet
+		return clips, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("[Event Recorder] cannot read directory '%s', error: %v", dir, err)
+	}
+
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, clipPrefix) || filepath.Ext(name) != ".json" {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("[Event Recorder] cannot read file '%s', error: %v", name, err)
+		}
+		var clip ClipInfo
+		if err := json.Unmarshal(data, &clip); err != nil {
+			return nil, fmt.Errorf("[Event Recorder] cannot parse file '%s', error: %v", name, err)
+		}
+		clips = append(clips, clip)
+	}
+
+	sort.Slice(clips, func(i, j int) bool {
+		return clips[i].Start.Before(clips[j].Start)
+	})
+
+	return clips, nil
+}
+
+// clipsState is JSON representation of the event recorder state
+type clipsState struct {
+	Recording bool       `json:"recording"`
+	Clips     []ClipInfo `json:"clips"`
+}
+
+// HTTPHandler is a handler for HTTP server, ex. "/clips" and "/clips/":
+// GET /clips returns metadata of all clips, GET /clips/<clip video or metadata file> returns the file
+func (r *EventRecorder) HTTPHandler(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(res, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if name := path.Base(req.URL.Path); strings.HasPrefix(name, clipPrefix) {
+		http.ServeFile(res, req, filepath.Join(r.Dir, name))
+		return
+	}
+
+	clips, err := ListClips(r.Dir)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(res).Encode(clipsState{
+		Recording: r.IsRecording(),
+		Clips:     clips,
+	})
+}
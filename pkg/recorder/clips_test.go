@@ -0,0 +1,141 @@
+package recorder
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/frame"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/mjpeg"
+)
+
+func TestClipsDoNotBlockFrames(t *testing.T) {
+	// the writer is stuck (ex. slow SD card), nobody reads the queue
+	queue := make(chan clipEntry, 3)
+	r := &EventRecorder{
+		FPS:         10,
+		MinDuration: 100 * time.Millisecond,
+		PreRoll:     200 * time.Millisecond,
+		PostRoll:    200 * time.Millisecond,
+		MaxDuration: time.Second,
+		started:     true,
+		buffer:      newRingBuffer(4),
+		queue:       queue,
+	}
+
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		start := time.Now()
+		for i := 0; i < 100; i++ {
+			activity := 0.0
+			if i%20 < 12 { // several clips
+				activity = 0.5
+			}
+			r.WriteFrame(frame.Frame{Seq: uint64(i), Time: start.Add(time.Duration(i) * 100 * time.Millisecond)}, activity)
+		}
+	}()
+
+	select {
+	case <-doneCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("frame loop is blocked by the clip writer")
+	}
+
+	// the writer catches up: operations are in order, frames are written only to open clips
+	r.Lock()
+	entries := r.pending
+	r.Unlock()
+	close(queue)
+	var ops []clipEntry
+	for e := range queue {
+		ops = append(ops, e)
+	}
+	ops = append(ops, entries...)
+
+	open := false
+	opens := 0
+	for i, e := range ops {
+		switch e.op {
+		case clipOpen:
+			if open {
+				t.Fatalf("op %d: clip is opened twice", i)
+			}
+			open = true
+			opens++
+		case clipWrite:
+			if !open {
+				t.Fatalf("op %d: frame is written without open clip", i)
+			}
+		case clipClose:
+			if !open {
+				t.Fatalf("op %d: clip is closed without open", i)
+			}
+			open = false
+		}
+	}
+	if r.Clips() < 5 {
+		t.Errorf("started clips: %d, expected: 5 or more", r.Clips())
+	}
+	if opens != int(r.Clips()) {
+		t.Errorf("opened clips: %d, started: %d", opens, r.Clips())
+	}
+}
+
+func TestRecoverClipInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the clip was interrupted: metadata is written on open, the video is not finalized
+	start := time.Date(2019, 3, 2, 18, 15, 3, 0, time.UTC)
+	info := ClipInfo{
+		Name:    "clip-20190302-181503-000",
+		Video:   "clip-20190302-181503-000.avi",
+		Start:   start,
+		Trigger: start.Add(time.Second),
+	}
+	if err := writeClipInfo(dir, info); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(filepath.Join(dir, info.Video))
+	if err != nil {
+		t.Fatal(err)
+	}
+	video, err := mjpeg.NewAVIWriter(f, 64, 48, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 25; i++ {
+		if err := video.WriteFrame([]byte(fmt.Sprintf("frame %d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	r := &EventRecorder{Dir: dir, Width: 64, Height: 48, FPS: 10}
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	r.Stop()
+
+	clips, err := ListClips(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clips) != 1 {
+		t.Fatalf("clips: %v", clips)
+	}
+	if clips[0].Frames != 25 {
+		t.Errorf("frames: %d, expected: 25", clips[0].Frames)
+	}
+	if clips[0].End == nil || !clips[0].End.Equal(start.Add(2400*time.Millisecond)) {
+		t.Errorf("end: %v, expected: %v", clips[0].End, start.Add(2400*time.Millisecond))
+	}
+}
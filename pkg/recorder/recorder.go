@@ -0,0 +1,473 @@
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/frame"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/mjpeg"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/safety"
+)
+
+// recorded streams
+const (
+	StreamRaw       = "raw"       // camera frames
+	StreamDebug     = "debug"     // debug frames with detection highlighting
+	streamTelemetry = "telemetry" // per frame telemetry, always recorded
+)
+
+// video file formats
+const (
+	FormatMJPEG = "mjpeg" // concatenated JPEG images (replay with -source-file)
+	FormatAVI   = "avi"   // MJPG video playable by common video players
+)
+
+var (
+	defaultSegmentDuration       = 5 * time.Minute
+	defaultMaxFileSize     int64 = 64 * 1024 * 1024
+	queueSize                    = 64
+	filePrefix                   = "session-"
+)
+
+// file extensions of the streams, video files have format extension
+var fileExtensions = map[string]string{
+	StreamRaw:       ".raw",
+	StreamDebug:     ".debug",
+	streamTelemetry: ".jsonl",
+}
+
+func fileExtension(stream, format string) string {
+	if stream == streamTelemetry {
+		return fileExtensions[stream]
+	}
+	return fileExtensions[stream] + "." + format
+}
+
+// entry is a frame or telemetry record to write
+type entry struct {
+	stream string
+	data   []byte
+}
+
+// Recorder writes frames of the streams to rotating files in the directory, along with per frame telemetry
+// in a JSONL sidecar file; files of a segment: session-<start time>-<segment>.raw.mjpeg, .debug.mjpeg and .jsonl
+// (.raw.avi and .debug.avi in AVI format). Files are written in background, frames are dropped if the disk is too slow.
+// Start time has milliseconds and a session counter, so files of a restarted session never overwrite previous ones.
+type Recorder struct {
+	Dir             string
+	Streams         []string      // StreamRaw and/or StreamDebug
+	Format          string        // FormatMJPEG (default) or FormatAVI
+	Width           int           // AVI frame size
+	Height          int           // AVI frame size
+	FPS             int           // AVI frame rate
+	SegmentDuration time.Duration // files are rotated after the duration
+	MaxFileSize     int64         // files are rotated when any of them exceeds the size
+	Quota           int64         // max size of all recordings in the directory, the oldest are removed, 0 - no limit
+
+	switchLock sync.Mutex // Start and Stop are serialized, so a session is never started twice
+
+	sync.Mutex
+	recording bool // false if stopped or writing failed
+	queue     chan entry
+	doneCh    chan struct{}
+	segment   string // current segment name
+	sessions  int    // number of started sessions
+}
+
+func (r *Recorder) setDefaults() {
+	if r.Format == "" {
+		r.Format = FormatMJPEG
+	}
+	if r.SegmentDuration == 0 {
+		r.SegmentDuration = defaultSegmentDuration
+	}
+	if r.MaxFileSize == 0 {
+		r.MaxFileSize = defaultMaxFileSize
+	}
+	// several segments fit the quota, so old ones can be removed
+	if r.Quota > 0 && r.MaxFileSize > r.Quota/8 {
+		r.MaxFileSize = r.Quota / 8
+	}
+}
+
+// Validate checks recorded stream names and video format
+func (r *Recorder) Validate() error {
+	for _, stream := range r.Streams {
+		if stream != StreamRaw && stream != StreamDebug {
+			return fmt.Errorf("[Recorder] unknown stream '%s', use: %s or %s", stream, StreamRaw, StreamDebug)
+		}
+	}
+	switch r.Format {
+	case "", FormatMJPEG:
+	case FormatAVI:
+		if r.Width <= 0 || r.Height <= 0 || r.FPS <= 0 {
+			return fmt.Errorf("[Recorder] invalid AVI video format: %dx%d@%d", r.Width, r.Height, r.FPS)
+		}
+	default:
+		return fmt.Errorf("[Recorder] unknown format '%s', use: %s or %s", r.Format, FormatMJPEG, FormatAVI)
+	}
+	return nil
+}
+
+// Records checks the stream is being recorded
+func (r *Recorder) Records(stream string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	if !r.recording {
+		return false
+	}
+	for _, s := range r.Streams {
+		if s == stream {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRecording returns true if recording is started
+func (r *Recorder) IsRecording() bool {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.recording
+}
+
+// sessionName returns unique session name, names are in chronological order
+func sessionName(now time.Time, counter int) string {
+	return fmt.Sprintf(
+		"%s%s-%03d-%02d",
+		filePrefix,
+		now.Format("20060102-150405"),
+		now.Nanosecond()/int(time.Millisecond),
+		counter%100,
+	)
+}
+
+// Start starts new recording session, current session is stopped
+func (r *Recorder) Start() error {
+	r.switchLock.Lock()
+	defer r.switchLock.Unlock()
+
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	r.stop()
+
+	// recovery of large files takes time, frames are not blocked meanwhile
+	if err := os.MkdirAll(r.Dir, 0755); err != nil {
+		return fmt.Errorf("[Recorder] cannot create directory '%s', error: %v", r.Dir, err)
+	}
+	recoverVideos(r.Dir, filePrefix, nil)
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.setDefaults()
+	r.recording = true
+	r.segment = ""
+	r.sessions++
+	r.queue = make(chan entry, queueSize)
+	r.doneCh = make(chan struct{})
+	go r.run(sessionName(time.Now(), r.sessions), r.queue, r.doneCh)
+
+	fmt.Printf("[Recorder] start: %s, streams: %s, format: %s\n", r.Dir, strings.Join(r.Streams, ", "), r.Format)
+
+	return nil
+}
+
+// Stop finishes recording, all queued frames are written
+func (r *Recorder) Stop() {
+	r.switchLock.Lock()
+	defer r.switchLock.Unlock()
+
+	r.stop()
+}
+
+// stop finishes current session, must be called with switchLock held
+func (r *Recorder) stop() {
+	r.Lock()
+	queue := r.queue
+	doneCh := r.doneCh
+	r.recording = false
+	r.queue = nil
+	r.Unlock()
+
+	if queue == nil {
+		return
+	}
+
+	close(queue)
+	<-doneCh
+
+	fmt.Println("[Recorder] stop")
+}
+
+// write queues the data, the data is dropped if the stream is not recorded or the queue is full
+func (r *Recorder) write(stream string, data []byte) {
+	r.Lock()
+	defer r.Unlock()
+
+	if !r.recording {
+		return
+	}
+
+	select {
+	case r.queue <- entry{stream: stream, data: data}:
+	default:
+		frame.Drops.Drop(frame.StageRecord)
+	}
+}
+
+// WriteRaw records camera frame
+func (r *Recorder) WriteRaw(data []byte) {
+	if r.Records(StreamRaw) {
+		r.write(StreamRaw, data)
+	}
+}
+
+// WriteDebug records debug frame
+func (r *Recorder) WriteDebug(data []byte) {
+	if r.Records(StreamDebug) {
+		r.write(StreamDebug, data)
+	}
+}
+
+// WriteTelemetry records the value as a JSON line
+func (r *Recorder) WriteTelemetry(v interface{}) {
+	if !r.IsRecording() {
+		return
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Printf("[Recorder] cannot encode telemetry, error: %v\n", err)
+		return
+	}
+	r.write(streamTelemetry, append(data, '\n'))
+}
+
+// run writes queued entries to segment files until the queue is closed
+func (r *Recorder) run(session string, queue chan entry, doneCh chan struct{}) {
+	defer safety.Guard()
+	defer close(doneCh)
+
+	var current *segment
+	index := 0
+	failed := false
+
+	for e := range queue {
+		if failed {
+			continue
+		}
+
+		if current != nil && current.full(r.SegmentDuration, r.MaxFileSize) {
+			if err := current.close(); err != nil {
+				fmt.Println(err)
+			}
+			current = nil
+		}
+
+		if current == nil {
+			index++
+			current = &segment{
+				dir:     r.Dir,
+				name:    fmt.Sprintf("%s-%04d", session, index),
+				format:  r.Format,
+				width:   r.Width,
+				height:  r.Height,
+				fps:     r.FPS,
+				started: time.Now(),
+				files:   map[string]*os.File{},
+				videos:  map[string]*mjpeg.AVIWriter{},
+				sizes:   map[string]int64{},
+			}
+			r.Lock()
+			r.segment = current.name
+			r.Unlock()
+			enforceQuota(r.Dir, filePrefix, r.Quota, current.name)
+		}
+
+		if err := current.write(e.stream, e.data); err != nil {
+			fmt.Println(err)
+			r.Lock()
+			r.recording = false
+			r.Unlock()
+			failed = true
+		}
+	}
+
+	if current != nil {
+		if err := current.close(); err != nil {
+			fmt.Println(err)
+		}
+		enforceQuota(r.Dir, filePrefix, r.Quota, current.name)
+	}
+}
+
+// recoverVideos finalizes AVI files with the prefix if the previous run was interrupted (ex. by power loss),
+// recovered is called for each recovered file if not nil
+func recoverVideos(dir, prefix string, recovered func(name string, frames int)) {
+	segments, err := listFiles(dir, prefix)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	for _, s := range segments {
+		for _, name := range s.Files {
+			if filepath.Ext(name) != "."+FormatAVI {
+				continue
+			}
+			frames, ok, err := mjpeg.RecoverAVI(filepath.Join(dir, name))
+			if err != nil {
+				fmt.Printf("[Recorder] cannot recover video '%s', error: %v\n", name, err)
+			} else if ok {
+				fmt.Printf("[Recorder] video %s is recovered: %d frames\n", name, frames)
+				if recovered != nil {
+					recovered(name, frames)
+				}
+			}
+		}
+	}
+}
+
+// enforceQuota removes the oldest files with the prefix (except the current ones) while they exceed the quota
+func enforceQuota(dir, prefix string, quota int64, current string) {
+	if quota <= 0 {
+		return
+	}
+
+	segments, err := listFiles(dir, prefix)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	var total int64
+	for _, s := range segments {
+		total += s.Size
+	}
+
+	for _, s := range segments {
+		if total <= quota || s.Name == current {
+			break
+		}
+		for _, name := range s.Files {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				fmt.Printf("[Recorder] cannot remove file '%s', error: %v\n", name, err)
+			}
+		}
+		total -= s.Size
+		fmt.Printf("[Recorder] %s is removed (quota: %.1f MB)\n", s.Name, float64(quota)/1024/1024)
+	}
+}
+
+// Segment is a set of recording files of the same time period
+type Segment struct {
+	Name  string   `json:"name"`
+	Files []string `json:"files"`
+	Size  int64    `json:"size"`
+}
+
+// ListSegments returns recorded segments in the directory, the oldest first
+func ListSegments(dir string) ([]Segment, error) {
+	return listFiles(dir, filePrefix)
+}
+
+// listFiles groups files with the prefix by name without extensions, the oldest first
+func listFiles(dir, prefix string) ([]Segment, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("[Recorder] cannot read directory '%s', error: %v", dir, err)
+	}
+
+	byName := map[string]*Segment{}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		base := name
+		if i := strings.Index(name, "."); i > 0 {
+			base = name[:i]
+		}
+		s, ok := byName[base]
+		if !ok {
+			s = &Segment{Name: base}
+			byName[base] = s
+		}
+		s.Files = append(s.Files, name)
+		s.Size += info.Size()
+	}
+
+	segments := make([]Segment, 0, len(byName))
+	for _, s := range byName {
+		segments = append(segments, *s)
+	}
+	// names start with the session time, so it's chronological order
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].Name < segments[j].Name
+	})
+
+	return segments, nil
+}
+
+// recorderState is JSON representation of the recorder state
+type recorderState struct {
+	Recording bool      `json:"recording"`
+	Streams   []string  `json:"streams,omitempty"`
+	Format    string    `json:"format,omitempty"`
+	Segment   string    `json:"segment,omitempty"`
+	Segments  []Segment `json:"segments,omitempty"`
+}
+
+// HTTPHandler is a handler for HTTP server:
+// GET returns recording state and recorded segments, POST/PUT with {"recording": true/false} starts or stops recording
+func (r *Recorder) HTTPHandler(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		var state recorderState
+		if err := json.NewDecoder(req.Body).Decode(&state); err != nil {
+			http.Error(res, fmt.Sprintf("cannot parse request: %s", err), http.StatusBadRequest)
+			return
+		}
+		if state.Recording && !r.IsRecording() {
+			if err := r.Start(); err != nil {
+				http.Error(res, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		} else if !state.Recording {
+			r.Stop()
+		}
+	default:
+		http.Error(res, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// format defaults are set by Start under the lock
+	r.Lock()
+	state := recorderState{
+		Recording: r.recording,
+		Streams:   r.Streams,
+		Format:    r.Format,
+	}
+	if state.Recording {
+		state.Segment = r.segment
+	}
+	r.Unlock()
+	state.Segments, _ = ListSegments(r.Dir)
+
+	res.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(res).Encode(state)
+}
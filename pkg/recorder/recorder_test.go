@@ -0,0 +1,139 @@
+package recorder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRestartKeepsFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := &Recorder{Dir: dir, Streams: []string{StreamRaw}}
+
+	// sessions are started within one second
+	frames := []string{"first", "second", "third"}
+	for _, data := range frames {
+		if err := r.Start(); err != nil {
+			t.Fatal(err)
+		}
+		r.WriteRaw([]byte(data))
+		r.Stop()
+	}
+
+	segments, err := ListSegments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != len(frames) {
+		t.Fatalf("segments: %v, expected: %d", segments, len(frames))
+	}
+	for i, s := range segments {
+		data, err := ioutil.ReadFile(filepath.Join(dir, s.Name+fileExtension(StreamRaw, FormatMJPEG)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != frames[i] {
+			t.Errorf("segment %s: %q, expected: %q", s.Name, data, frames[i])
+		}
+	}
+}
+
+func TestSessionName(t *testing.T) {
+	now := time.Date(2019, 3, 2, 18, 15, 3, 120*int(time.Millisecond), time.UTC)
+	if name := sessionName(now, 7); name != "session-20190302-181503-120-07" {
+		t.Errorf("session name: %s", name)
+	}
+	if sessionName(now, 7) >= sessionName(now.Add(time.Millisecond), 8) {
+		t.Error("session names are not in chronological order")
+	}
+}
+
+func TestConcurrentStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := &Recorder{Dir: dir, Streams: []string{StreamRaw}}
+	goroutines := runtime.NumGoroutine()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := r.Start(); err != nil {
+				t.Error(err)
+			}
+			r.WriteRaw([]byte("frame"))
+		}()
+	}
+	wg.Wait()
+	r.Stop()
+
+	if r.IsRecording() {
+		t.Error("recording after stop")
+	}
+
+	// all writers are stopped
+	for i := 0; runtime.NumGoroutine() > goroutines && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Errorf("goroutines: %d, expected: %d", n, goroutines)
+	}
+}
+
+func TestHTTPHandlerDuringStart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r := &Recorder{Dir: dir, Streams: []string{StreamRaw}}
+
+	// state is read while the recording is started and stopped (run with -race)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 5; i++ {
+			if err := r.Start(); err != nil {
+				t.Error(err)
+			}
+			r.Stop()
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		res := httptest.NewRecorder()
+		r.HTTPHandler(res, httptest.NewRequest(http.MethodGet, "/record", nil))
+		if res.Code != http.StatusOK {
+			t.Fatalf("status: %d", res.Code)
+		}
+	}
+	wg.Wait()
+
+	res := httptest.NewRecorder()
+	r.HTTPHandler(res, httptest.NewRequest(http.MethodGet, "/record", nil))
+	var state recorderState
+	if err := json.NewDecoder(res.Body).Decode(&state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Recording || state.Format != FormatMJPEG {
+		t.Errorf("state: %+v", state)
+	}
+}
@@ -0,0 +1,60 @@
+package recorder
+
+import (
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/frame"
+)
+
+// clipFrame is a frame with motion activity
+type clipFrame struct {
+	frame.Frame
+	activity float64
+}
+
+// ringBuffer keeps the last frames, the oldest frame is overwritten when the buffer is full
+type ringBuffer struct {
+	frames []clipFrame
+	start  int // the oldest frame index
+	size   int
+}
+
+func (b *ringBuffer) push(f clipFrame) {
+	end := (b.start + b.size) % len(b.frames)
+	b.frames[end] = f
+	if b.size < len(b.frames) {
+		b.size++
+	} else {
+		b.start = (b.start + 1) % len(b.frames)
+	}
+}
+
+// since returns frames captured at the time or later, the oldest first
+func (b *ringBuffer) since(t time.Time) []clipFrame {
+	frames := make([]clipFrame, 0, b.size)
+	for i := 0; i < b.size; i++ {
+		f := b.frames[(b.start+i)%len(b.frames)]
+		if !f.Time.Before(t) {
+			frames = append(frames, f)
+		}
+	}
+	return frames
+}
+
+// reset removes all frames
+func (b *ringBuffer) reset() {
+	for i := range b.frames {
+		b.frames[i] = clipFrame{} // release image data
+	}
+	b.start = 0
+	b.size = 0
+}
+
+func newRingBuffer(capacity int) *ringBuffer {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &ringBuffer{
+		frames: make([]clipFrame, capacity),
+	}
+}
@@ -0,0 +1,92 @@
+package recorder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/mjpeg"
+)
+
+// segment is a set of open recording files, files are created on the first write
+type segment struct {
+	dir     string
+	name    string
+	format  string
+	width   int
+	height  int
+	fps     int
+	started time.Time
+	files   map[string]*os.File         // by stream
+	videos  map[string]*mjpeg.AVIWriter // by stream, AVI format only
+	sizes   map[string]int64            // by stream
+}
+
+// full checks the segment must be rotated
+func (s *segment) full(maxDuration time.Duration, maxFileSize int64) bool {
+	if time.Since(s.started) >= maxDuration {
+		return true
+	}
+	for _, size := range s.sizes {
+		if size >= maxFileSize {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *segment) write(stream string, data []byte) error {
+	f, ok := s.files[stream]
+	if !ok {
+		path := filepath.Join(s.dir, s.name+fileExtension(stream, s.format))
+		var err error
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return fmt.Errorf("[Recorder] cannot create file '%s', error: %v", path, err)
+		}
+		s.files[stream] = f
+
+		if s.format == FormatAVI && stream != streamTelemetry {
+			video, err := mjpeg.NewAVIWriter(f, s.width, s.height, s.fps)
+			if err != nil {
+				return fmt.Errorf("[Recorder] cannot create video '%s', error: %v", path, err)
+			}
+			s.videos[stream] = video
+		}
+	}
+
+	if video, ok := s.videos[stream]; ok {
+		err := video.WriteFrame(data)
+		s.sizes[stream] = video.Size()
+		if err != nil {
+			return fmt.Errorf("[Recorder] cannot write video '%s', error: %v", f.Name(), err)
+		}
+		return nil
+	}
+
+	n, err := f.Write(data)
+	s.sizes[stream] += int64(n)
+	if err != nil {
+		return fmt.Errorf("[Recorder] cannot write file '%s', error: %v", f.Name(), err)
+	}
+
+	return nil
+}
+
+func (s *segment) close() error {
+	var closeErr error
+	for stream, f := range s.files {
+		if video, ok := s.videos[stream]; ok {
+			if err := video.Close(); err != nil && closeErr == nil {
+				closeErr = fmt.Errorf("[Recorder] cannot finalize video '%s', error: %v", f.Name(), err)
+			}
+			delete(s.videos, stream)
+		}
+		if err := f.Close(); err != nil && closeErr == nil {
+			closeErr = fmt.Errorf("[Recorder] cannot close file '%s', error: %v", f.Name(), err)
+		}
+		delete(s.files, stream)
+	}
+	return closeErr
+}
@@ -0,0 +1,35 @@
+package safety
+
+import (
+	"sync"
+)
+
+var (
+	mu    sync.Mutex
+	hooks []func()
+)
+
+// OnPanic registers a function that puts hardware to a safe state (ex. turns the laser off)
+// when a guarded goroutine panics, the function must not wait for locks
+func OnPanic(hook func()) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	hooks = append(hooks, hook)
+}
+
+// Guard calls registered functions if the goroutine panics, then the panic continues,
+// every long-lived goroutine uses it: defer safety.Guard()
+func Guard() {
+	if r := recover(); r != nil {
+		mu.Lock()
+		registered := make([]func(), len(hooks))
+		copy(registered, hooks)
+		mu.Unlock()
+
+		for _, hook := range registered {
+			hook()
+		}
+		panic(r)
+	}
+}
@@ -0,0 +1,86 @@
+package servo
+
+import (
+	"sync"
+)
+
+// Actuator is a hardware-agnostic controller of a single axis (X or Y)
+type Actuator interface {
+	// SetPercent - set actuator position in percent [0.0-1.0]
+	SetPercent(val float64)
+
+	// Percent returns last commanded position in percent [0.0-1.0]
+	Percent() float64
+
+	// Release stops holding the position (servo can be turned freely)
+	Release() error
+}
+
+// MemoryActuator is an in-memory actuator that records all commanded positions,
+// it doesn't require any hardware and can be used for testing and simulation
+type MemoryActuator struct {
+	sync.Mutex
+
+	percent   float64
+	positions []float64
+	released  bool
+}
+
+// SetPercent - record new position in percent [0.0-1.0]
+func (a *MemoryActuator) SetPercent(val float64) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.percent = val
+	a.positions = append(a.positions, val)
+	a.released = false
+}
+
+// Percent returns last commanded position in percent [0.0-1.0]
+func (a *MemoryActuator) Percent() float64 {
+	a.Lock()
+	defer a.Unlock()
+
+	return a.percent
+}
+
+// Release marks actuator as released
+func (a *MemoryActuator) Release() error {
+	a.Lock()
+	defer a.Unlock()
+
+	a.released = true
+	return nil
+}
+
+// Released returns true if actuator was released and no position was set after that
+func (a *MemoryActuator) Released() bool {
+	a.Lock()
+	defer a.Unlock()
+
+	return a.released
+}
+
+// Positions returns a copy of all recorded positions
+func (a *MemoryActuator) Positions() []float64 {
+	a.Lock()
+	defer a.Unlock()
+
+	positions := make([]float64, len(a.positions))
+	copy(positions, a.positions)
+
+	return positions
+}
+
+// Reset clears recorded positions
+func (a *MemoryActuator) Reset() {
+	a.Lock()
+	defer a.Unlock()
+
+	a.positions = nil
+}
+
+// NewMemoryActuator creates new in-memory actuator
+func NewMemoryActuator() *MemoryActuator {
+	return &MemoryActuator{}
+}
@@ -4,24 +4,29 @@ import (
 	"math"
 	"sync"
 	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/safety"
 )
 
 const floatEpsilon = 0.001
 
+// tickInterval is the dot movement step interval
+var tickInterval = time.Second / 200
+
 func distance(x0, y0, x1, y1 float64) float64 {
 	return math.Sqrt(math.Pow(x0-x1, 2) + math.Pow(y0-y1, 2))
 }
 
 //PercentPoint - a point percent values of XY
 type PercentPoint struct {
-	X float64
-	Y float64
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
 }
 
 //FieldXY is a two-dimensional field that controls two servos (one for X, and one for Y axes)
 type FieldXY struct {
-	ServoX *Servo
-	ServoY *Servo
+	ServoX Actuator
+	ServoY Actuator
 	FlipX  bool
 	FlipY  bool
 
@@ -32,10 +37,17 @@ type FieldXY struct {
 	currentY      float64
 	targetX       float64
 	targetY       float64
+	waypoints     []PercentPoint // the rest of the route around keep-out zones
+	keepOut       []Zone
 	cancelNoiseCh chan struct{}
+	moves         uint64 // number of servos position updates
+	closed        bool
+	stopCh        chan struct{}
 }
 
-func (f *FieldXY) tick() {
+// Tick moves the dot one step to the target, it's called every tick interval
+// unless the field is created by NewManualFieldXY
+func (f *FieldXY) Tick() {
 	f.Lock()
 	currentX := f.currentX
 	currentY := f.currentY
@@ -45,6 +57,14 @@ func (f *FieldXY) tick() {
 
 	d := distance(currentX, currentY, targetX, targetY)
 	if d < floatEpsilon {
+		// go to the next waypoint
+		f.Lock()
+		if len(f.waypoints) > 0 {
+			f.targetX = f.waypoints[0].X
+			f.targetY = f.waypoints[0].Y
+			f.waypoints = f.waypoints[1:]
+		}
+		f.Unlock()
 		return
 	}
 
@@ -62,11 +82,26 @@ func (f *FieldXY) tick() {
 	f.SetPoint(currentX+dX, currentY+dY)
 }
 
-// SetPoint moves servos to a single point on the field
+// SetPoint moves servos to a single point on the field,
+// a point inside a keep-out zone is replaced by the closest point outside,
+// a move across a zone is ignored
 func (f *FieldXY) SetPoint(x, y float64) {
 	f.Lock()
+	if len(f.keepOut) > 0 {
+		current := PercentPoint{X: f.currentX, Y: f.currentY}
+		p, ok := safePoint(f.keepOut, PercentPoint{X: x, Y: y})
+		if !ok || (!inZones(f.keepOut, current) && blocked(f.keepOut, current, p)) {
+			f.Unlock()
+			return
+		}
+		x = p.X
+		y = p.Y
+	}
 	f.currentX = x
 	f.currentY = y
+	f.moves++
+	flipX := f.FlipX
+	flipY := f.FlipY
 	f.Unlock()
 
 	select {
@@ -77,31 +112,137 @@ func (f *FieldXY) SetPoint(x, y float64) {
 	default:
 	}
 
-	if f.FlipX {
+	if flipX {
 		x = 1 - x
 	}
-	if f.FlipY {
+	if flipY {
 		y = 1 - y
 	}
 	f.ServoX.SetPercent(x)
 	f.ServoY.SetPercent(y)
 }
 
-// LineTo - smooth movement to the point from current position
+// LineTo - smooth movement to the point from current position, the point is limited by the field,
+// the route goes around keep-out zones
 func (f *FieldXY) LineTo(x, y float64) {
+	f.lineTo(x, y)
+}
+
+// lineTo starts movement to the point, returns false if the dot stays because there is no safe point or route
+func (f *FieldXY) lineTo(x, y float64) bool {
+	x = math.Max(0, math.Min(1, x))
+	y = math.Max(0, math.Min(1, y))
+
+	f.Lock()
+	defer f.Unlock()
+
+	f.waypoints = nil
+	if len(f.keepOut) == 0 {
+		f.targetX = x
+		f.targetY = y
+		return true
+	}
+
+	// stay if there is no safe point or route
+	f.targetX = f.currentX
+	f.targetY = f.currentY
+
+	target, ok := safePoint(f.keepOut, PercentPoint{X: x, Y: y})
+	if !ok {
+		return false
+	}
+
+	// the dot can be inside a zone added after it was moved
+	from, ok := safePoint(f.keepOut, PercentPoint{X: f.currentX, Y: f.currentY})
+	if !ok {
+		return false
+	}
+
+	route, ok := planRoute(f.keepOut, from, target)
+	if !ok {
+		return false
+	}
+	if from.X != f.currentX || from.Y != f.currentY {
+		route = append([]PercentPoint{from}, route...)
+	}
+
+	f.targetX = route[0].X
+	f.targetY = route[0].Y
+	f.waypoints = route[1:]
+
+	return true
+}
+
+// SetKeepOut sets zones the dot never goes to, current movement is re-planned
+func (f *FieldXY) SetKeepOut(zones []Zone) {
 	f.Lock()
-	f.targetX = x
-	f.targetY = y
+	f.keepOut = zones
+	targetX := f.targetX
+	targetY := f.targetY
+	if len(f.waypoints) > 0 {
+		targetX = f.waypoints[len(f.waypoints)-1].X
+		targetY = f.waypoints[len(f.waypoints)-1].Y
+	}
 	f.Unlock()
+
+	f.LineTo(targetX, targetY)
+}
+
+// SetFlip changes servos position calculation, the dot is moved to the flipped position
+func (f *FieldXY) SetFlip(flipX, flipY bool) {
+	f.Lock()
+	f.FlipX = flipX
+	f.FlipY = flipY
+	currentX := f.currentX
+	currentY := f.currentY
+	f.Unlock()
+
+	f.SetPoint(currentX, currentY)
+}
+
+// Moves returns number of servos position updates
+func (f *FieldXY) Moves() uint64 {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.moves
+}
+
+// KeepOut returns keep-out zones
+func (f *FieldXY) KeepOut() []Zone {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.keepOut
+}
+
+// CurrentPoint returns current dot position
+func (f *FieldXY) CurrentPoint() PercentPoint {
+	f.Lock()
+	defer f.Unlock()
+
+	return PercentPoint{
+		X: f.currentX,
+		Y: f.currentY,
+	}
 }
 
 // RunAway from the point
 func (f *FieldXY) RunAway(x, y, radius float64, alwaysStayOnRadius bool) {
 	f.Lock()
-	dotX := f.currentX * 4
-	dotY := f.currentY * 3
+	dotX := f.currentX
+	dotY := f.currentY
 	f.Unlock()
 
+	f.LineTo(RunAwayPoint(dotX, dotY, x, y, radius, alwaysStayOnRadius))
+}
+
+// RunAwayPoint returns the closest point for the dot at (dotX, dotY) on the "keep away" circle
+// around the point (x, y), all coordinates are in percent [0.0-1.0]
+func RunAwayPoint(dotX, dotY, x, y, radius float64, alwaysStayOnRadius bool) (float64, float64) {
+	dotX = dotX * 4
+	dotY = dotY * 3
+
 	const W = 1.0 * 4
 	const H = 1.0 * 3
 
@@ -114,7 +255,8 @@ func (f *FieldXY) RunAway(x, y, radius float64, alwaysStayOnRadius bool) {
 	kaX := x + (keepAwayR*(dotX-x))/math.Sqrt(math.Pow(dotX-x, 2)+math.Pow(dotY-y, 2))
 	kaY := y + (keepAwayR*(dotY-y))/math.Sqrt(math.Pow(dotX-x, 2)+math.Pow(dotY-y, 2))
 
-	if !alwaysStayOnRadius && distance(x, y, dotX, dotY) < distance(x, y, kaX, kaY) {
+	// the dot inside the radius runs away, the dot outside is pulled onto the radius only in follow mode
+	if alwaysStayOnRadius || distance(x, y, dotX, dotY) < distance(x, y, kaX, kaY) {
 		dotX = kaX
 		dotY = kaY
 	}
@@ -178,24 +320,34 @@ func (f *FieldXY) RunAway(x, y, radius float64, alwaysStayOnRadius bool) {
 		}
 	}
 
-	f.LineTo(dotX/4, dotY/3)
+	return dotX / 4, dotY / 3
 }
 
 // SetRandomMovements - move dot a little bit
 // step=0 to disable random movements
 func (f *FieldXY) SetRandomMovements(step float64, interval time.Duration) {
 	// cancel previous noise motions if running
-	select {
-	case f.cancelNoiseCh <- struct{}{}:
-	default:
+	f.Lock()
+	if f.cancelNoiseCh != nil {
+		close(f.cancelNoiseCh)
+		f.cancelNoiseCh = nil
 	}
+	cancelCh := make(chan struct{})
+	if step > 0 && interval > 0 {
+		f.cancelNoiseCh = cancelCh
+	}
+	f.Unlock()
 
-	if step > 0 {
+	if step > 0 && interval > 0 {
 		go func() {
-			ticker := time.NewTicker(time.Second * 3)
+			defer safety.Guard()
+			ticker := time.NewTicker(interval)
 			for {
 				select {
-				case <-f.cancelNoiseCh:
+				case <-cancelCh:
+					ticker.Stop()
+					return
+				case <-f.stopCh:
 					ticker.Stop()
 					return
 				case <-ticker.C:
@@ -236,23 +388,73 @@ func (f *FieldXY) MoveRandom(step float64) {
 	f.SetPoint(x, y)
 }
 
-// NewFieldXY creates new FieldXY
-func NewFieldXY(servoX, servoY *Servo, flipX, flipY bool) *FieldXY {
-	fieldXY := &FieldXY{
+// Park moves the dot to the point and waits until it is reached or timeout expires,
+// returns false if the point is not reached or there is no route to it
+func (f *FieldXY) Park(x, y float64, timeout time.Duration) bool {
+	if !f.lineTo(x, y) {
+		return false
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		f.Lock()
+		reached := len(f.waypoints) == 0 && distance(f.currentX, f.currentY, f.targetX, f.targetY) < floatEpsilon
+		f.Unlock()
+
+		if reached {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return false
+}
+
+// Close stops dot movements and releases servos
+func (f *FieldXY) Close() error {
+	f.Lock()
+	if f.closed {
+		f.Unlock()
+		return nil
+	}
+	f.closed = true
+	close(f.stopCh)
+	f.Unlock()
+
+	errX := f.ServoX.Release()
+	errY := f.ServoY.Release()
+	if errX != nil {
+		return errX
+	}
+	return errY
+}
+
+// NewManualFieldXY creates new FieldXY which dot moves only on Tick calls (ex. in tests)
+func NewManualFieldXY(servoX, servoY Actuator, flipX, flipY bool) *FieldXY {
+	return &FieldXY{
 		ServoX:                servoX,
 		ServoY:                servoY,
 		FlipX:                 flipX,
 		FlipY:                 flipY,
 		CurrentPercentPointCh: make(chan PercentPoint),
-		cancelNoiseCh:         make(chan struct{}),
+		stopCh:                make(chan struct{}),
 	}
+}
+
+// NewFieldXY creates new FieldXY
+func NewFieldXY(servoX, servoY Actuator, flipX, flipY bool) *FieldXY {
+	fieldXY := NewManualFieldXY(servoX, servoY, flipX, flipY)
 
-	ticker := time.NewTicker(time.Second / 200)
+	ticker := time.NewTicker(tickInterval)
 	go func() {
+		defer safety.Guard()
 		for {
 			select {
+			case <-fieldXY.stopCh:
+				ticker.Stop()
+				return
 			case <-ticker.C:
-				fieldXY.tick()
+				fieldXY.Tick()
 			}
 		}
 	}()
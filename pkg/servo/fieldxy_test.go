@@ -0,0 +1,223 @@
+package servo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// newTestField creates a manual field with in-memory actuators
+func newTestField(flipX, flipY bool) (*FieldXY, *MemoryActuator, *MemoryActuator) {
+	servoX := NewMemoryActuator()
+	servoY := NewMemoryActuator()
+	return NewManualFieldXY(servoX, servoY, flipX, flipY), servoX, servoY
+}
+
+// tickAll moves the dot till the end of the route, returns number of ticks
+func tickAll(t *testing.T, f *FieldXY) int {
+	for i := 0; i < 1000; i++ {
+		f.Lock()
+		reached := len(f.waypoints) == 0 && distance(f.currentX, f.currentY, f.targetX, f.targetY) < floatEpsilon
+		f.Unlock()
+		if reached {
+			return i
+		}
+		f.Tick()
+	}
+	t.Fatal("the dot doesn't reach the target in 1000 ticks")
+	return 0
+}
+
+// assertPoint checks the point with float tolerance
+func assertPoint(t *testing.T, name string, p PercentPoint, x, y float64) {
+	t.Helper()
+	if math.Abs(p.X-x) > floatEpsilon || math.Abs(p.Y-y) > floatEpsilon {
+		t.Errorf("%s: point (%.3f, %.3f), expected (%.3f, %.3f)", name, p.X, p.Y, x, y)
+	}
+}
+
+func TestMemoryActuator(t *testing.T) {
+	a := NewMemoryActuator()
+	a.SetPercent(0.2)
+	a.SetPercent(0.7)
+
+	if a.Percent() != 0.7 {
+		t.Errorf("percent: %f, expected: 0.7", a.Percent())
+	}
+	positions := a.Positions()
+	if len(positions) != 2 || positions[0] != 0.2 || positions[1] != 0.7 {
+		t.Errorf("positions: %v", positions)
+	}
+
+	// positions are copied
+	positions[0] = 1
+	if a.Positions()[0] != 0.2 {
+		t.Error("positions are changed outside of the actuator")
+	}
+
+	if err := a.Release(); err != nil || !a.Released() {
+		t.Errorf("actuator is not released, error: %v", err)
+	}
+	a.SetPercent(0.5)
+	if a.Released() {
+		t.Error("actuator is released after a new position")
+	}
+
+	a.Reset()
+	if len(a.Positions()) != 0 || a.Percent() != 0.5 {
+		t.Errorf("reset clears the position or keeps positions: %v", a.Positions())
+	}
+}
+
+func TestTick(t *testing.T) {
+	f, servoX, servoY := newTestField(false, false)
+	f.LineTo(1, 0.5)
+
+	ticks := tickAll(t, f)
+	if ticks < 100 {
+		t.Errorf("the dot jumps to the target in %d ticks", ticks)
+	}
+	assertPoint(t, "current", f.CurrentPoint(), 1, 0.5)
+
+	// steps are small and go along the line
+	xs := servoX.Positions()
+	ys := servoY.Positions()
+	prev := PercentPoint{}
+	for i := range xs {
+		if d := distance(prev.X, prev.Y, xs[i], ys[i]); d > 0.02 {
+			t.Fatalf("step %d is too large: %f", i, d)
+		}
+		if math.Abs(ys[i]-xs[i]/2) > floatEpsilon {
+			t.Fatalf("step %d is off the line: (%f, %f)", i, xs[i], ys[i])
+		}
+		prev = PercentPoint{X: xs[i], Y: ys[i]}
+	}
+	if f.Moves() != uint64(len(xs)) {
+		t.Errorf("moves: %d, expected: %d", f.Moves(), len(xs))
+	}
+
+	// the dot stays at the target
+	servoX.Reset()
+	f.Tick()
+	if len(servoX.Positions()) != 0 {
+		t.Error("the dot moves at the target")
+	}
+}
+
+func TestTickFlip(t *testing.T) {
+	f, servoX, servoY := newTestField(true, false)
+	f.LineTo(0.2, 0.3)
+	tickAll(t, f)
+
+	assertPoint(t, "field", f.CurrentPoint(), 0.2, 0.3)
+	assertPoint(t, "servos", PercentPoint{X: servoX.Percent(), Y: servoY.Percent()}, 0.8, 0.3)
+
+	f.SetFlip(false, true)
+	assertPoint(t, "flipped servos", PercentPoint{X: servoX.Percent(), Y: servoY.Percent()}, 0.2, 0.7)
+}
+
+func TestRunAway(t *testing.T) {
+	tests := []struct {
+		name     string
+		dot      PercentPoint
+		cat      PercentPoint
+		follow   bool // the dot stays on radius
+		expected PercentPoint
+	}{
+		{"inside radius", PercentPoint{X: 0.5, Y: 0.5}, PercentPoint{X: 0.4, Y: 0.5}, false, PercentPoint{X: 0.6, Y: 0.5}},
+		{"outside radius", PercentPoint{X: 0.9, Y: 0.5}, PercentPoint{X: 0.4, Y: 0.5}, false, PercentPoint{X: 0.9, Y: 0.5}},
+		{"outside radius, follow", PercentPoint{X: 0.9, Y: 0.5}, PercentPoint{X: 0.4, Y: 0.5}, true, PercentPoint{X: 0.6, Y: 0.5}},
+		{"inside radius, follow", PercentPoint{X: 0.5, Y: 0.5}, PercentPoint{X: 0.4, Y: 0.5}, true, PercentPoint{X: 0.6, Y: 0.5}},
+		{"vertical", PercentPoint{X: 0.5, Y: 0.4}, PercentPoint{X: 0.5, Y: 0.5}, false, PercentPoint{X: 0.5, Y: 0.5 - 0.2*4/3}},
+		// the circle point is out of the field, the dot goes to the circle and the right edge intersection
+		{"pushed out", PercentPoint{X: 0.95, Y: 0.6}, PercentPoint{X: 0.9, Y: 0.5}, false, PercentPoint{X: 1, Y: 0.5 + math.Sqrt(0.8*0.8-0.4*0.4)/3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, _, _ := newTestField(false, false)
+			f.SetPoint(test.dot.X, test.dot.Y)
+
+			f.RunAway(test.cat.X, test.cat.Y, 0.2, test.follow)
+			tickAll(t, f)
+
+			assertPoint(t, test.name, f.CurrentPoint(), test.expected.X, test.expected.Y)
+		})
+	}
+}
+
+func TestMoveRandom(t *testing.T) {
+	const step = 0.05
+
+	tests := []struct {
+		name     string
+		dot      PercentPoint
+		expected *PercentPoint // nil for a random direction
+	}{
+		{"center", PercentPoint{X: 0.5, Y: 0.5}, nil},
+		{"top left corner", PercentPoint{X: 0, Y: 0}, &PercentPoint{X: step, Y: step}},
+		{"bottom right corner", PercentPoint{X: 1, Y: 1}, &PercentPoint{X: 1 - step, Y: 1 - step}},
+		{"right edge", PercentPoint{X: 1, Y: 0}, &PercentPoint{X: 1 - step, Y: step}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, _, _ := newTestField(false, false)
+			f.SetPoint(test.dot.X, test.dot.Y)
+
+			f.MoveRandom(step)
+			p := f.CurrentPoint()
+
+			if test.expected != nil {
+				assertPoint(t, test.name, p, test.expected.X, test.expected.Y)
+				return
+			}
+			if math.Abs(math.Abs(p.X-test.dot.X)-step) > floatEpsilon || math.Abs(math.Abs(p.Y-test.dot.Y)-step) > floatEpsilon {
+				t.Errorf("the dot is moved from (%.3f, %.3f) to (%.3f, %.3f), expected step: %.3f",
+					test.dot.X, test.dot.Y, p.X, p.Y, step)
+			}
+		})
+	}
+
+	// the dot never leaves the field
+	f, _, _ := newTestField(false, false)
+	f.SetPoint(0.98, 0.02)
+	for i := 0; i < 1000; i++ {
+		f.MoveRandom(step)
+		if p := f.CurrentPoint(); p.X < 0 || p.X > 1 || p.Y < 0 || p.Y > 1 {
+			t.Fatalf("the dot is out of the field: (%.3f, %.3f)", p.X, p.Y)
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	f, servoX, servoY := newTestField(false, false)
+	f.SetPoint(0.5, 0.5)
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !servoX.Released() || !servoY.Released() {
+		t.Error("servos are not released")
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("second close error: %v", err)
+	}
+}
+
+func TestPark(t *testing.T) {
+	f := NewFieldXY(NewMemoryActuator(), NewMemoryActuator(), false, false)
+	defer f.Close()
+
+	if !f.Park(0.3, 0.2, 5*time.Second) {
+		t.Fatal("the dot is not parked")
+	}
+	assertPoint(t, "parked", f.CurrentPoint(), 0.3, 0.2)
+
+	// the zone splits the field, there is no route to the park point
+	f.SetKeepOut([]Zone{{{X: 0.4, Y: -0.1}, {X: 0.6, Y: -0.1}, {X: 0.6, Y: 1.1}, {X: 0.4, Y: 1.1}}})
+	if f.Park(0.9, 0.5, time.Second) {
+		t.Error("the dot is parked without route")
+	}
+	assertPoint(t, "not parked", f.CurrentPoint(), 0.3, 0.2)
+}
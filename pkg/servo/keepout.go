@@ -0,0 +1,280 @@
+package servo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+)
+
+// keepOutMargin is a gap between the dot route and keep-out zones, percent
+var keepOutMargin = 0.02
+
+// Zone is a keep-out polygon in field coordinates (percent [0.0-1.0] before servo flipping),
+// the laser dot is never moved inside or across it
+type Zone []PercentPoint
+
+// contains checks the point is inside the zone or on its border
+func (z Zone) contains(p PercentPoint) bool {
+	inside := false
+	for i, j := 0, len(z)-1; i < len(z); j, i = i, i+1 {
+		c := closestOnSegment(p, z[j], z[i])
+		if distance(p.X, p.Y, c.X, c.Y) < floatEpsilon/100 {
+			return true
+		}
+		if (z[i].Y > p.Y) != (z[j].Y > p.Y) &&
+			p.X < (z[j].X-z[i].X)*(p.Y-z[i].Y)/(z[j].Y-z[i].Y)+z[i].X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// crosses checks the segment goes through the zone
+func (z Zone) crosses(a, b PercentPoint) bool {
+	for i, j := 0, len(z)-1; i < len(z); j, i = i, i+1 {
+		if segmentsIntersect(a, b, z[j], z[i]) {
+			return true
+		}
+	}
+
+	// segment between two vertices can go through the zone without proper intersections
+	return z.contains(PercentPoint{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2})
+}
+
+// escapePoints returns points outside the zone (with margin) next to the closest points of each edge
+func (z Zone) escapePoints(p PercentPoint) []PercentPoint {
+	points := make([]PercentPoint, 0, len(z))
+	for i, j := 0, len(z)-1; i < len(z); j, i = i, i+1 {
+		c := closestOnSegment(p, z[j], z[i])
+
+		// edge normal that points outside
+		nx, ny := normalize(z[j].Y-z[i].Y, z[i].X-z[j].X)
+		q := PercentPoint{X: c.X + nx*keepOutMargin, Y: c.Y + ny*keepOutMargin}
+		if z.contains(q) {
+			q = PercentPoint{X: c.X - nx*keepOutMargin, Y: c.Y - ny*keepOutMargin}
+		}
+		points = append(points, q)
+	}
+	return points
+}
+
+// inflated returns zone vertices moved outside by the margin, they are route waypoints around the zone
+func (z Zone) inflated() []PercentPoint {
+	points := make([]PercentPoint, 0, len(z))
+	for i := range z {
+		prev := z[(i+len(z)-1)%len(z)]
+		next := z[(i+1)%len(z)]
+
+		// bisector of the edges, pointing away from both neighbours
+		ax, ay := normalize(z[i].X-prev.X, z[i].Y-prev.Y)
+		bx, by := normalize(z[i].X-next.X, z[i].Y-next.Y)
+		dx, dy := normalize(ax+bx, ay+by)
+		if dx == 0 && dy == 0 {
+			dx, dy = -ay, ax
+		}
+
+		// a bit further than the margin, so routes along the edges keep the gap
+		p := PercentPoint{X: z[i].X + dx*keepOutMargin*1.5, Y: z[i].Y + dy*keepOutMargin*1.5}
+		if z.contains(p) {
+			// reflex vertex
+			p = PercentPoint{X: z[i].X - dx*keepOutMargin*1.5, Y: z[i].Y - dy*keepOutMargin*1.5}
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+// normalize returns the vector of length 1
+func normalize(x, y float64) (float64, float64) {
+	l := math.Sqrt(x*x + y*y)
+	if l == 0 {
+		return 0, 0
+	}
+	return x / l, y / l
+}
+
+// closestOnSegment returns the point of the segment a-b closest to p
+func closestOnSegment(p, a, b PercentPoint) PercentPoint {
+	dx := b.X - a.X
+	dy := b.Y - a.Y
+	l2 := dx*dx + dy*dy
+	if l2 == 0 {
+		return a
+	}
+
+	t := math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/l2))
+
+	return PercentPoint{X: a.X + t*dx, Y: a.Y + t*dy}
+}
+
+// segmentsIntersect checks segments a-b and c-d have a common point
+func segmentsIntersect(a, b, c, d PercentPoint) bool {
+	orientation := func(p, q, r PercentPoint) float64 {
+		return (q.X-p.X)*(r.Y-p.Y) - (q.Y-p.Y)*(r.X-p.X)
+	}
+	onSegment := func(p, q, r PercentPoint) bool {
+		return math.Min(p.X, q.X) <= r.X && r.X <= math.Max(p.X, q.X) &&
+			math.Min(p.Y, q.Y) <= r.Y && r.Y <= math.Max(p.Y, q.Y)
+	}
+
+	o1 := orientation(a, b, c)
+	o2 := orientation(a, b, d)
+	o3 := orientation(c, d, a)
+	o4 := orientation(c, d, b)
+
+	if ((o1 > 0 && o2 < 0) || (o1 < 0 && o2 > 0)) && ((o3 > 0 && o4 < 0) || (o3 < 0 && o4 > 0)) {
+		return true
+	}
+
+	return (o1 == 0 && onSegment(a, b, c)) ||
+		(o2 == 0 && onSegment(a, b, d)) ||
+		(o3 == 0 && onSegment(c, d, a)) ||
+		(o4 == 0 && onSegment(c, d, b))
+}
+
+// inZones checks the point is inside any of the zones
+func inZones(zones []Zone, p PercentPoint) bool {
+	for _, z := range zones {
+		if z.contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// blocked checks the segment goes through any of the zones
+func blocked(zones []Zone, a, b PercentPoint) bool {
+	for _, z := range zones {
+		if z.crosses(a, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// clampPoint limits the point by the field
+func clampPoint(p PercentPoint) PercentPoint {
+	return PercentPoint{
+		X: math.Max(0, math.Min(1, p.X)),
+		Y: math.Max(0, math.Min(1, p.Y)),
+	}
+}
+
+// safePoint returns the closest to p point on the field outside of all zones,
+// false if there is no such point (ex. zones cover the field)
+func safePoint(zones []Zone, p PercentPoint) (PercentPoint, bool) {
+	p = clampPoint(p)
+	if !inZones(zones, p) {
+		return p, true
+	}
+
+	found := false
+	closest := p
+	minDistance := math.Inf(1)
+	for _, z := range zones {
+		for _, q := range z.escapePoints(p) {
+			if q.X < 0 || q.X > 1 || q.Y < 0 || q.Y > 1 || inZones(zones, q) {
+				continue
+			}
+			if d := distance(p.X, p.Y, q.X, q.Y); d < minDistance {
+				minDistance = d
+				closest = q
+				found = true
+			}
+		}
+	}
+
+	return closest, found
+}
+
+// planRoute returns waypoints from one point to another around the zones (visibility graph),
+// the last waypoint is the destination, false if there is no route
+func planRoute(zones []Zone, from, to PercentPoint) ([]PercentPoint, bool) {
+	if !blocked(zones, from, to) {
+		return []PercentPoint{to}, true
+	}
+
+	// graph nodes: start, finish and points around zone vertices
+	nodes := []PercentPoint{from, to}
+	for _, z := range zones {
+		for _, p := range z.inflated() {
+			if p.X < 0 || p.X > 1 || p.Y < 0 || p.Y > 1 || inZones(zones, p) {
+				continue
+			}
+			nodes = append(nodes, p)
+		}
+	}
+
+	// Dijkstra's algorithm from the start node, visibility is checked on demand
+	const start, finish = 0, 1
+	dist := make([]float64, len(nodes))
+	prev := make([]int, len(nodes))
+	done := make([]bool, len(nodes))
+	for i := range dist {
+		dist[i] = math.Inf(1)
+		prev[i] = -1
+	}
+	dist[start] = 0
+
+	for {
+		current := -1
+		for i := range nodes {
+			if !done[i] && !math.IsInf(dist[i], 1) && (current == -1 || dist[i] < dist[current]) {
+				current = i
+			}
+		}
+		if current == -1 {
+			return nil, false
+		}
+		if current == finish {
+			break
+		}
+		done[current] = true
+
+		for i := range nodes {
+			if done[i] {
+				continue
+			}
+			d := dist[current] + distance(nodes[current].X, nodes[current].Y, nodes[i].X, nodes[i].Y)
+			if d < dist[i] && !blocked(zones, nodes[current], nodes[i]) {
+				dist[i] = d
+				prev[i] = current
+			}
+		}
+	}
+
+	route := []PercentPoint{}
+	for i := finish; i != start; i = prev[i] {
+		route = append(route, nodes[i])
+	}
+	for i, j := 0, len(route)-1; i < j; i, j = i+1, j-1 {
+		route[i], route[j] = route[j], route[i]
+	}
+
+	return route, true
+}
+
+// LoadZones reads keep-out zones from JSON file:
+// {"zones": [[{"x": 0.1, "y": 0.1}, {"x": 0.3, "y": 0.1}, {"x": 0.3, "y": 0.4}], ...]}
+func LoadZones(path string) ([]Zone, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("[FieldXY] cannot read keep-out file '%s', error: %v", path, err)
+	}
+
+	var config struct {
+		Zones []Zone `json:"zones"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("[FieldXY] cannot parse keep-out file '%s', error: %v", path, err)
+	}
+
+	for _, z := range config.Zones {
+		if len(z) < 3 {
+			return nil, fmt.Errorf("[FieldXY] keep-out zone must have at least 3 points in file '%s'", path)
+		}
+	}
+
+	return config.Zones, nil
+}
@@ -0,0 +1,197 @@
+package servo
+
+import (
+	"testing"
+)
+
+var (
+	// square in the middle of the field
+	convexZone = Zone{{X: 0.4, Y: 0.4}, {X: 0.6, Y: 0.4}, {X: 0.6, Y: 0.6}, {X: 0.4, Y: 0.6}}
+
+	// cup opened to the top
+	concaveZone = Zone{
+		{X: 0.3, Y: 0.3}, {X: 0.4, Y: 0.3}, {X: 0.4, Y: 0.6}, {X: 0.6, Y: 0.6},
+		{X: 0.6, Y: 0.3}, {X: 0.7, Y: 0.3}, {X: 0.7, Y: 0.7}, {X: 0.3, Y: 0.7},
+	}
+)
+
+// assertOutside checks all servos positions are outside of the zones
+func assertOutside(t *testing.T, zones []Zone, servoX, servoY *MemoryActuator) {
+	t.Helper()
+	xs := servoX.Positions()
+	ys := servoY.Positions()
+	for i := range xs {
+		if p := (PercentPoint{X: xs[i], Y: ys[i]}); inZones(zones, p) {
+			t.Fatalf("the dot is inside a zone at step %d: (%.3f, %.3f)", i, p.X, p.Y)
+		}
+	}
+}
+
+func TestSafePoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		zones    []Zone
+		point    PercentPoint
+		expected PercentPoint
+		ok       bool
+	}{
+		{"outside", []Zone{convexZone}, PercentPoint{X: 0.2, Y: 0.2}, PercentPoint{X: 0.2, Y: 0.2}, true},
+		{"out of field", nil, PercentPoint{X: 1.5, Y: -1}, PercentPoint{X: 1, Y: 0}, true},
+		{"inside, close to the left edge", []Zone{convexZone}, PercentPoint{X: 0.41, Y: 0.5}, PercentPoint{X: 0.4 - keepOutMargin, Y: 0.5}, true},
+		{"inside, close to the bottom edge", []Zone{convexZone}, PercentPoint{X: 0.5, Y: 0.58}, PercentPoint{X: 0.5, Y: 0.6 + keepOutMargin}, true},
+		{"inside the cup wall", []Zone{concaveZone}, PercentPoint{X: 0.39, Y: 0.4}, PercentPoint{X: 0.4 + keepOutMargin, Y: 0.4}, true},
+		{"zone covers the field", []Zone{{{X: -1, Y: -1}, {X: 2, Y: -1}, {X: 2, Y: 2}, {X: -1, Y: 2}}}, PercentPoint{X: 0.5, Y: 0.5}, PercentPoint{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, ok := safePoint(test.zones, test.point)
+			if ok != test.ok {
+				t.Fatalf("ok: %t, expected: %t", ok, test.ok)
+			}
+			if !ok {
+				return
+			}
+			assertPoint(t, test.name, p, test.expected.X, test.expected.Y)
+			if inZones(test.zones, p) {
+				t.Errorf("safe point (%.3f, %.3f) is inside a zone", p.X, p.Y)
+			}
+		})
+	}
+}
+
+func TestPlanRoute(t *testing.T) {
+	tests := []struct {
+		name      string
+		zones     []Zone
+		from      PercentPoint
+		to        PercentPoint
+		waypoints int // minimal number of waypoints, the last one is the destination
+	}{
+		{"no zones", nil, PercentPoint{X: 0.1, Y: 0.5}, PercentPoint{X: 0.9, Y: 0.5}, 1},
+		{"not blocked", []Zone{convexZone}, PercentPoint{X: 0.1, Y: 0.1}, PercentPoint{X: 0.9, Y: 0.1}, 1},
+		{"around convex", []Zone{convexZone}, PercentPoint{X: 0.1, Y: 0.5}, PercentPoint{X: 0.9, Y: 0.5}, 3},
+		{"out of concave", []Zone{concaveZone}, PercentPoint{X: 0.5, Y: 0.5}, PercentPoint{X: 0.5, Y: 0.9}, 3},
+		{"into concave", []Zone{concaveZone}, PercentPoint{X: 0.5, Y: 0.9}, PercentPoint{X: 0.5, Y: 0.5}, 3},
+		{"between zones", []Zone{convexZone, {{X: 0.7, Y: 0}, {X: 0.8, Y: 0}, {X: 0.8, Y: 0.8}, {X: 0.7, Y: 0.8}}},
+			PercentPoint{X: 0.5, Y: 0.2}, PercentPoint{X: 0.9, Y: 0.5}, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			route, ok := planRoute(test.zones, test.from, test.to)
+			if !ok {
+				t.Fatal("route is not found")
+			}
+			if len(route) < test.waypoints {
+				t.Errorf("waypoints: %d, expected at least: %d", len(route), test.waypoints)
+			}
+			if route[len(route)-1] != test.to {
+				t.Errorf("the route ends at %v, expected: %v", route[len(route)-1], test.to)
+			}
+
+			prev := test.from
+			for _, p := range route {
+				if blocked(test.zones, prev, p) {
+					t.Errorf("the route goes through a zone: %v - %v", prev, p)
+				}
+				prev = p
+			}
+		})
+	}
+
+	// the zone splits the field
+	wall := Zone{{X: 0.4, Y: -0.1}, {X: 0.6, Y: -0.1}, {X: 0.6, Y: 1.1}, {X: 0.4, Y: 1.1}}
+	if route, ok := planRoute([]Zone{wall}, PercentPoint{X: 0.1, Y: 0.5}, PercentPoint{X: 0.9, Y: 0.5}); ok {
+		t.Errorf("route through the wall: %v", route)
+	}
+}
+
+func TestFieldKeepOut(t *testing.T) {
+	tests := []struct {
+		name     string
+		zone     Zone
+		from     PercentPoint
+		to       PercentPoint
+		expected PercentPoint
+	}{
+		{"around convex", convexZone, PercentPoint{X: 0.1, Y: 0.5}, PercentPoint{X: 0.9, Y: 0.5}, PercentPoint{X: 0.9, Y: 0.5}},
+		{"around concave", concaveZone, PercentPoint{X: 0.5, Y: 0.5}, PercentPoint{X: 0.5, Y: 0.9}, PercentPoint{X: 0.5, Y: 0.9}},
+		{"target inside", convexZone, PercentPoint{X: 0.1, Y: 0.5}, PercentPoint{X: 0.42, Y: 0.5}, PercentPoint{X: 0.4 - keepOutMargin, Y: 0.5}},
+		{"target inside, far side", convexZone, PercentPoint{X: 0.1, Y: 0.5}, PercentPoint{X: 0.59, Y: 0.5}, PercentPoint{X: 0.6 + keepOutMargin, Y: 0.5}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, servoX, servoY := newTestField(false, false)
+			f.SetPoint(test.from.X, test.from.Y)
+			f.SetKeepOut([]Zone{test.zone})
+
+			f.LineTo(test.to.X, test.to.Y)
+			tickAll(t, f)
+
+			assertPoint(t, test.name, f.CurrentPoint(), test.expected.X, test.expected.Y)
+			assertOutside(t, []Zone{test.zone}, servoX, servoY)
+		})
+	}
+}
+
+func TestFieldKeepOutFlip(t *testing.T) {
+	// zones are in field coordinates, servos positions are flipped
+	f, servoX, servoY := newTestField(true, true)
+	f.SetKeepOut([]Zone{convexZone})
+
+	f.SetPoint(0.5, 0.41)
+	assertPoint(t, "field", f.CurrentPoint(), 0.5, 0.4-keepOutMargin)
+	assertPoint(t, "servos", PercentPoint{X: servoX.Percent(), Y: servoY.Percent()}, 0.5, 0.6+keepOutMargin)
+}
+
+func TestFieldNewZoneOverDot(t *testing.T) {
+	f, servoX, servoY := newTestField(false, false)
+	f.SetPoint(0.5, 0.45)
+	servoX.Reset()
+	servoY.Reset()
+
+	// the dot leaves the new zone by the shortest way and goes on around it
+	f.SetKeepOut([]Zone{convexZone})
+	f.LineTo(0.5, 0.9)
+	tickAll(t, f)
+
+	assertPoint(t, "current", f.CurrentPoint(), 0.5, 0.9)
+	xs := servoX.Positions()
+	ys := servoY.Positions()
+	if len(xs) == 0 {
+		t.Fatal("the dot doesn't move")
+	}
+	assertPoint(t, "first move", PercentPoint{X: xs[0], Y: ys[0]}, 0.5, 0.4-keepOutMargin)
+	assertOutside(t, []Zone{convexZone}, servoX, servoY)
+
+	// the dot doesn't go back into the zone
+	f.LineTo(0.5, 0.5)
+	tickAll(t, f)
+	if inZones([]Zone{convexZone}, f.CurrentPoint()) {
+		t.Errorf("the dot is inside the zone: %v", f.CurrentPoint())
+	}
+}
+
+func TestFieldRandomMovesKeepOut(t *testing.T) {
+	zones := []Zone{convexZone, concaveZone}
+
+	// start points inside the cup wall, inside both zones, under the cup and in the corner
+	for _, start := range []PercentPoint{{X: 0.38, Y: 0.5}, {X: 0.5, Y: 0.55}, {X: 0.5, Y: 0.72}, {X: 0.05, Y: 0.05}} {
+		f, servoX, servoY := newTestField(false, false)
+		f.SetPoint(start.X, start.Y)
+		f.SetKeepOut(zones)
+		servoX.Reset()
+		servoY.Reset()
+
+		for i := 0; i < 500; i++ {
+			f.MoveRandom(0.03)
+			if i%10 == 0 {
+				f.LineTo(float64(i%7)/6, float64(i%5)/4)
+			}
+			f.Tick()
+		}
+		assertOutside(t, zones, servoX, servoY)
+	}
+}
@@ -0,0 +1,123 @@
+package servo
+
+import (
+	"math"
+	"time"
+)
+
+// predictive run-away settings, distances are in 4 x 3 field units
+var (
+	runAwayCandidates  = 48   // number of escape points checked around predicted position
+	runAwayHorizon     = 1.0  // how far ahead the path is avoided, * time.Second of cat movement
+	runAwayWallMargin  = 0.4  // escape points closer to the walls are penalized
+	runAwayPathWeight  = 10.0 // penalty weight for escape points on the predicted path
+	runAwayWallWeight  = 4.0  // penalty weight for escape points near walls (doubled in corners)
+	runAwayCrossWeight = 6.0  // penalty weight for dot route crossing the cat
+	runAwayMoveWeight  = 0.2  // penalty weight for long dot moves
+)
+
+// distanceToSegment returns distance from point (x, y) to segment (x0, y0)-(x1, y1)
+func distanceToSegment(x, y, x0, y0, x1, y1 float64) float64 {
+	dx := x1 - x0
+	dy := y1 - y0
+	l2 := dx*dx + dy*dy
+	if l2 < floatEpsilon*floatEpsilon {
+		return distance(x, y, x0, y0)
+	}
+
+	t := ((x-x0)*dx + (y-y0)*dy) / l2
+	t = math.Max(0, math.Min(1, t))
+
+	return distance(x, y, x0+t*dx, y0+t*dy)
+}
+
+// PredictiveRunAwayPoint returns escape point for the dot at (dotX, dotY) from the cat at (x, y)
+// moving with velocity (vx, vy) percent per second. The cat position is predicted after latency,
+// and the escape point is chosen on the radius around it avoiding the predicted path, walls and corners.
+// All coordinates are in percent [0.0-1.0].
+func PredictiveRunAwayPoint(
+	dotX, dotY, x, y, vx, vy float64,
+	latency time.Duration,
+	radius float64,
+	alwaysStayOnRadius bool,
+) (float64, float64) {
+	const W = 1.0 * 4
+	const H = 1.0 * 3
+
+	dotX = dotX * 4
+	dotY = dotY * 3
+	x = x * 4
+	y = y * 3
+	vx = vx * 4
+	vy = vy * 3
+
+	keepAwayR := radius * 4
+
+	// predicted cat position after servo latency
+	px := math.Max(0, math.Min(W, x+vx*latency.Seconds()))
+	py := math.Max(0, math.Min(H, y+vy*latency.Seconds()))
+
+	// the end of the path the cat is going to run
+	hx := px + vx*runAwayHorizon
+	hy := py + vy*runAwayHorizon
+
+	dotToPath := distanceToSegment(dotX, dotY, px, py, hx, hy)
+	if !alwaysStayOnRadius && dotToPath >= keepAwayR {
+		return dotX / 4, dotY / 3
+	}
+
+	bestScore := math.Inf(1)
+	bestX := dotX
+	bestY := dotY
+	for i := 0; i < runAwayCandidates; i++ {
+		angle := 2 * math.Pi * float64(i) / float64(runAwayCandidates)
+		cx := math.Max(0, math.Min(W, px+keepAwayR*math.Cos(angle)))
+		cy := math.Max(0, math.Min(H, py+keepAwayR*math.Sin(angle)))
+
+		score := 0.0
+
+		// on the predicted path (or clamped too close to the cat)
+		if d := distanceToSegment(cx, cy, px, py, hx, hy); d < keepAwayR {
+			score += runAwayPathWeight * (keepAwayR - d)
+		}
+
+		// near walls, corners have penalties from two walls
+		if m := math.Min(cx, W-cx); m < runAwayWallMargin {
+			score += runAwayWallWeight * (runAwayWallMargin - m)
+		}
+		if m := math.Min(cy, H-cy); m < runAwayWallMargin {
+			score += runAwayWallWeight * (runAwayWallMargin - m)
+		}
+
+		// dot route goes through the cat
+		if d := distanceToSegment(px, py, dotX, dotY, cx, cy); d < keepAwayR/2 {
+			score += runAwayCrossWeight * (keepAwayR/2 - d)
+		}
+
+		score += runAwayMoveWeight * distance(dotX, dotY, cx, cy)
+
+		if score < bestScore {
+			bestScore = score
+			bestX = cx
+			bestY = cy
+		}
+	}
+
+	return bestX / 4, bestY / 3
+}
+
+// RunAwayPredictive from the point moving with velocity (vx, vy) percent per second,
+// see PredictiveRunAwayPoint
+func (f *FieldXY) RunAwayPredictive(
+	x, y, vx, vy float64,
+	latency time.Duration,
+	radius float64,
+	alwaysStayOnRadius bool,
+) {
+	f.Lock()
+	dotX := f.currentX
+	dotY := f.currentY
+	f.Unlock()
+
+	f.LineTo(PredictiveRunAwayPoint(dotX, dotY, x, y, vx, vy, latency, radius, alwaysStayOnRadius))
+}
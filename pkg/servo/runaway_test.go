@@ -0,0 +1,85 @@
+package servo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// fieldDistance returns distance between two points in percent, in 4 x 3 field units
+func fieldDistance(x0, y0, x1, y1 float64) float64 {
+	return distance(x0*4, y0*3, x1*4, y1*3)
+}
+
+func TestPredictiveRunAwayPoint(t *testing.T) {
+	latency := 100 * time.Millisecond
+	radius := 0.2
+
+	tests := []struct {
+		name             string
+		dotX, dotY       float64
+		x, y, vx, vy     float64
+		check            func(x, y float64) bool
+		checkDescription string
+	}{
+		{
+			"the dot is away from the path",
+			0.2, 0.2, 0.6, 0.6, 0.2, 0,
+			func(x, y float64) bool { return math.Abs(x-0.2) < 1e-9 && math.Abs(y-0.2) < 1e-9 },
+			"the dot doesn't move",
+		},
+		{
+			"the sitting cat",
+			0.52, 0.5, 0.5, 0.5, 0, 0,
+			func(x, y float64) bool { return math.Abs(fieldDistance(x, y, 0.5, 0.5)-radius*4) < 1e-9 },
+			"the dot is on the radius",
+		},
+		{
+			"the cat runs at the dot",
+			0.6, 0.5, 0.3, 0.5, 0.5, 0,
+			func(x, y float64) bool {
+				// the predicted path: (0.35, 0.5)-(0.85, 0.5)
+				return distanceToSegment(x*4, y*3, 0.35*4, 0.5*3, 0.85*4, 0.5*3) >= radius*4-1e-9
+			},
+			"the dot leaves the predicted path",
+		},
+		{
+			"the cat drives the dot to the corner",
+			0.95, 0.95, 0.7, 0.7, 0.3, 0.3,
+			func(x, y float64) bool {
+				return !(x > 0.9 && y > 0.9) && fieldDistance(x, y, 0.73, 0.73) >= radius*4-1e-9
+			},
+			"the dot escapes from the corner",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			x, y := PredictiveRunAwayPoint(test.dotX, test.dotY, test.x, test.y, test.vx, test.vy, latency, radius, false)
+			if x < 0 || x > 1 || y < 0 || y > 1 {
+				t.Fatalf("escape point is out of the field: (%.3f, %.3f)", x, y)
+			}
+			if !test.check(x, y) {
+				t.Errorf("escape point: (%.3f, %.3f), expected: %s", x, y, test.checkDescription)
+			}
+		})
+	}
+}
+
+func TestPredictiveRunAwayDirection(t *testing.T) {
+	// the geometric run-away pushes the dot ahead of the running cat, the predictive one steps aside
+	x, y := RunAwayPoint(0.55, 0.5, 0.4, 0.5, 0.2, false)
+	if math.Abs(y-0.5) > 1e-9 || x <= 0.55 {
+		t.Errorf("geometric escape point: (%.3f, %.3f), expected ahead of the cat", x, y)
+	}
+	x, y = PredictiveRunAwayPoint(0.55, 0.5, 0.4, 0.5, 0.5, 0, 100*time.Millisecond, 0.2, false)
+	if math.Abs(y-0.5) < 0.1 {
+		t.Errorf("predictive escape point: (%.3f, %.3f), expected to step aside the path", x, y)
+	}
+
+	// the dot is kept on the radius even away from the path
+	x, y = PredictiveRunAwayPoint(0.1, 0.1, 0.5, 0.5, 0, 0, 0, 0.2, true)
+	if d := fieldDistance(x, y, 0.5, 0.5); math.Abs(d-0.8) > 1e-9 {
+		t.Errorf("escape point: (%.3f, %.3f), distance: %.3f, expected: 0.8", x, y, d)
+	}
+}
@@ -2,6 +2,7 @@ package servo
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/stianeikeland/go-rpio"
 )
@@ -40,7 +41,7 @@ var (
 	DefaultMaxAnglePulseLength uint32 = 114
 )
 
-// Servo controls a servo connected to the PWM pin
+// Servo controls a servo connected to the PWM pin, it is the Raspberry Pi PWM implementation of Actuator
 type Servo struct {
 	//Pin is
 	Pin RpiPwmPin
@@ -66,18 +67,56 @@ type Servo struct {
 
 	rpioPin  rpio.Pin
 	pwmCycle uint32
+
+	mu      sync.Mutex
+	percent float64
 }
 
 var l uint32
 
 // SetPercent - set servo angle in percent [0.0-1.0]
 func (s *Servo) SetPercent(val float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// calculate available duty range
 	rangeFrom := s.pwmCycle * s.MinAnglePulseLength / 1000
 	rangeTo := s.pwmCycle * s.MaxAnglePulseLength / 1000
 
 	duty := rangeFrom + ((rangeTo-rangeFrom)*uint32(val*10000))/10000
 	s.rpioPin.DutyCycle(duty, s.pwmCycle)
+
+	s.percent = val
+}
+
+// SetRange changes min and max angle pulse lengths [1-999], the servo is moved to the same percent of the new range
+func (s *Servo) SetRange(minAnglePulseLength, maxAnglePulseLength uint32) {
+	s.mu.Lock()
+	s.MinAnglePulseLength = minAnglePulseLength
+	s.MaxAnglePulseLength = maxAnglePulseLength
+	percent := s.percent
+	s.mu.Unlock()
+
+	fmt.Printf("[Servo] range: pin:%v, minAP: %v, maxAP:%v\n", s.Pin, minAnglePulseLength, maxAnglePulseLength)
+
+	s.SetPercent(percent)
+}
+
+// Percent returns last set servo angle in percent [0.0-1.0]
+func (s *Servo) Percent() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.percent
+}
+
+// Release stops sending pulses to the servo, so it doesn't hold the angle anymore
+func (s *Servo) Release() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rpioPin.DutyCycle(0, s.pwmCycle)
+	return nil
 }
 
 // NewServo - create new servo controller
@@ -0,0 +1,218 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/drawer"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/frame"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/safety"
+)
+
+var (
+	defaultWidth     = 4 * 32
+	defaultHeight    = 3 * 32
+	defaultFPS       = 15
+	defaultCatRadius = 0.06 // as percent of room width
+	defaultCatSpeed  = 0.25 // as percent of room width per second
+)
+
+// colors of the synthetic room
+var (
+	colorFloor     = color.RGBA{150, 140, 120, 255}
+	colorFloorTile = color.RGBA{140, 130, 110, 255}
+	colorFurniture = color.RGBA{90, 70, 60, 255}
+	colorCat       = color.RGBA{40, 40, 40, 255}
+	colorDot       = color.RGBA{255, 0, 0, 255}
+)
+
+// Room renders a synthetic room with a moving "cat" blob and the laser dot,
+// it produces the same stream of jpeg images as raspivid.ImageStream does
+type Room struct {
+	Width     int
+	Height    int
+	FPS       int
+	CatRadius float64 // cat size as percent of width
+	CatSpeed  float64 // cat max speed as percent of width per second
+
+	sync.Mutex
+	dotX     float64
+	dotY     float64
+	laserOff bool
+	catX     float64
+	catY     float64
+	restTill time.Time
+	random   *rand.Rand
+}
+
+func (r *Room) setDefaults() {
+	if r.FPS == 0 {
+		r.FPS = defaultFPS
+	}
+	if r.Width == 0 {
+		r.Width = defaultWidth
+	}
+	if r.Height == 0 {
+		r.Height = defaultHeight
+	}
+	if r.CatRadius == 0 {
+		r.CatRadius = defaultCatRadius
+	}
+	if r.CatSpeed == 0 {
+		r.CatSpeed = defaultCatSpeed
+	}
+}
+
+// SetDot sets current laser dot position in percent [0.0-1.0]
+func (r *Room) SetDot(x, y float64) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.dotX = x
+	r.dotY = y
+}
+
+// SetLaser turns the laser on and off, the dot is not rendered if the laser is off
+func (r *Room) SetLaser(on bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.laserOff = !on
+}
+
+// moveCat makes the cat stalk the laser dot with random pauses
+func (r *Room) moveCat(now time.Time, dt float64) {
+	r.Lock()
+	defer r.Unlock()
+
+	if now.Before(r.restTill) {
+		return
+	}
+
+	// sometimes cat sits and watches
+	if r.random.Float64() < 0.01 {
+		r.restTill = now.Add(time.Duration(500+r.random.Intn(2000)) * time.Millisecond)
+		return
+	}
+
+	// 4 x 3 space to keep same speed for both axes
+	dx := (r.dotX - r.catX) * 4
+	dy := (r.dotY - r.catY) * 3
+	d := math.Sqrt(dx*dx + dy*dy)
+
+	step := r.CatSpeed * 4 * dt * (0.5 + r.random.Float64())
+	if d > step {
+		dx = dx * step / d
+		dy = dy * step / d
+	}
+
+	// a bit of random walking
+	dx += (r.random.Float64() - 0.5) * step
+	dy += (r.random.Float64() - 0.5) * step
+
+	r.catX = math.Min(math.Max(r.catX+dx/4, 0), 1)
+	r.catY = math.Min(math.Max(r.catY+dy/3, 0), 1)
+}
+
+// render draws current room state
+func (r *Room) render() image.RGBA {
+	r.Lock()
+	catX := int(r.catX * float64(r.Width))
+	catY := int(r.catY * float64(r.Height))
+	dotX := int(r.dotX * float64(r.Width))
+	dotY := int(r.dotY * float64(r.Height))
+	laserOn := !r.laserOff
+	r.Unlock()
+
+	img := image.NewRGBA(image.Rect(0, 0, r.Width, r.Height))
+
+	tileSize := r.Width / 8
+	if tileSize < 1 {
+		tileSize = 1
+	}
+	catR := int(r.CatRadius * float64(r.Width))
+	dotR := r.Width / 100
+	if dotR < 1 {
+		dotR = 1
+	}
+
+	for x := 0; x < r.Width; x++ {
+		for y := 0; y < r.Height; y++ {
+			c := colorFloor
+			if (x/tileSize+y/tileSize)%2 == 0 {
+				c = colorFloorTile
+			}
+
+			// sofa along the top wall
+			if y < r.Height/8 && x > r.Width/4 && x < r.Width*3/4 {
+				c = colorFurniture
+			}
+
+			if (x-catX)*(x-catX)+(y-catY)*(y-catY)*2 <= catR*catR {
+				c = colorCat
+			}
+
+			if laserOn && (x-dotX)*(x-dotX)+(y-dotY)*(y-dotY) <= dotR*dotR {
+				c = colorDot
+			}
+
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	return *img
+}
+
+// Start returns a channel of rendered images, the channel is closed when context is cancelled
+func (r *Room) Start(ctx context.Context) (chan frame.Frame, error) {
+	r.setDefaults()
+
+	fmt.Printf("[Simulator Room] start: %dx%d@%d\n", r.Width, r.Height, r.FPS)
+
+	r.Lock()
+	r.random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	r.catX = r.random.Float64()
+	r.catY = r.random.Float64()
+	r.Unlock()
+
+	seq := &frame.Sequence{Source: "simulator"}
+	ch := make(chan frame.Frame)
+
+	go func() {
+		defer safety.Guard()
+		defer close(ch)
+
+		interval := time.Second / time.Duration(r.FPS)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			var now time.Time
+			select {
+			case <-ctx.Done():
+				return
+			case now = <-ticker.C:
+			}
+
+			r.moveCat(now, interval.Seconds())
+
+			imgDrawer := drawer.New(r.render())
+			imageBytes := imgDrawer.JpegBytes(90)
+
+			// try to send new image to the channel
+			select {
+			case ch <- seq.Next(imageBytes):
+			default:
+				frame.Drops.Drop(frame.StageCapture)
+			}
+		}
+	}()
+
+	return ch, nil
+}
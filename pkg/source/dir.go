@@ -0,0 +1,97 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/frame"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/safety"
+)
+
+// Dir iterates JPEG images in a directory in file name order,
+// all images are delivered to the channel (nothing is dropped) to keep replay deterministic,
+// images are stamped with capture time at native rate starting from the first image modification time
+type Dir struct {
+	Path  string
+	FPS   int     // images per second at replay speed 1
+	Speed float64 // replay speed: 1 - native rate, 2 - two times faster, 0 - as fast as possible
+	Loop  bool    // start over after the last image
+}
+
+// imagePaths returns sorted list of JPEG files in the directory
+func (s *Dir) imagePaths() ([]string, error) {
+	files, err := ioutil.ReadDir(s.Path) // sorted by file name
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f.Name()))
+		if !f.IsDir() && (ext == ".jpg" || ext == ".jpeg") {
+			paths = append(paths, filepath.Join(s.Path, f.Name()))
+		}
+	}
+
+	return paths, nil
+}
+
+// Start returns a channel of images
+func (s *Dir) Start(ctx context.Context) (chan frame.Frame, error) {
+	fmt.Printf("[Dir Source] replay %s, speed: %v, loop: %v\n", s.Path, s.Speed, s.Loop)
+
+	paths, err := s.imagePaths()
+	if err != nil {
+		return nil, fmt.Errorf("[Dir Source] cannot read directory, error: %v", err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("[Dir Source] no JPEG images found in '%s'", s.Path)
+	}
+
+	first, err := os.Stat(paths[0])
+	if err != nil {
+		return nil, fmt.Errorf("[Dir Source] cannot read image, error: %v", err)
+	}
+
+	interval := frameInterval(s.FPS, s.Speed)
+	seq := &frame.Sequence{Source: "dir:" + s.Path}
+	times := &timeline{start: first.ModTime(), interval: frameInterval(s.FPS, 1)}
+	ch := make(chan frame.Frame)
+
+	go func() {
+		defer safety.Guard()
+		defer close(ch)
+		for {
+			for _, path := range paths {
+				startTime := time.Now()
+
+				imageBytes, err := ioutil.ReadFile(path)
+				if err != nil {
+					fmt.Printf("[Dir Source] read image error: %s\n", err)
+					return
+				}
+
+				select {
+				case ch <- seq.NextAt(imageBytes, times.next()):
+				case <-ctx.Done():
+					return
+				}
+
+				if !sleep(ctx, interval-time.Since(startTime)) {
+					return
+				}
+			}
+			fmt.Printf("[Dir Source] %d images replayed\n", len(paths))
+			if !s.Loop {
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
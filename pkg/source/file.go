@@ -0,0 +1,95 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/frame"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/mjpeg"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/safety"
+)
+
+// File replays a recorded MJPEG file (concatenated JPEG images),
+// all images are delivered to the channel (nothing is dropped) to keep replay deterministic,
+// images are stamped with capture time at native rate starting from the file modification time
+type File struct {
+	Path  string
+	FPS   int     // native recording fps
+	Speed float64 // replay speed: 1 - native rate, 2 - two times faster, 0 - as fast as possible
+	Loop  bool    // start over after the last image
+}
+
+// replay sends all file images to the channel, returns number of sent images
+func (s *File) replay(ctx context.Context, seq *frame.Sequence, times *timeline, ch chan frame.Frame) (int, error) {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	interval := frameInterval(s.FPS, s.Speed)
+	reader := mjpeg.NewReader(f)
+
+	count := 0
+	for {
+		startTime := time.Now()
+
+		imageBytes, err := reader.ReadFrame()
+		if frameErr, ok := err.(*mjpeg.FrameError); ok {
+			fmt.Println(frameErr)
+			frame.Drops.Drop(frame.StageCorrupt)
+			continue
+		} else if err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, err
+		}
+
+		select {
+		case ch <- seq.NextAt(imageBytes, times.next()):
+			count++
+		case <-ctx.Done():
+			return count, nil
+		}
+
+		if !sleep(ctx, interval-time.Since(startTime)) {
+			return count, nil
+		}
+	}
+}
+
+// Start returns a channel of images
+func (s *File) Start(ctx context.Context) (chan frame.Frame, error) {
+	fmt.Printf("[File Source] replay %s, speed: %v, loop: %v\n", s.Path, s.Speed, s.Loop)
+
+	// check the file exists before starting
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("[File Source] cannot open file, error: %v", err)
+	}
+
+	seq := &frame.Sequence{Source: "file:" + s.Path}
+	times := &timeline{start: info.ModTime(), interval: frameInterval(s.FPS, 1)}
+	ch := make(chan frame.Frame)
+
+	go func() {
+		defer safety.Guard()
+		defer close(ch)
+		for {
+			count, err := s.replay(ctx, seq, times, ch)
+			if err != nil {
+				fmt.Printf("[File Source] replay error: %s\n", err)
+				return
+			}
+			fmt.Printf("[File Source] %d images replayed\n", count)
+			if !s.Loop || count == 0 || ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
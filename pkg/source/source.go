@@ -0,0 +1,64 @@
+package source
+
+import (
+	"context"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/frame"
+)
+
+var (
+	defaultFPS           = 15
+	defaultRetryInterval = time.Second
+)
+
+// FrameSource is a source of JPEG images,
+// the returned channel is closed when the source has no more images or context is cancelled
+type FrameSource interface {
+	Start(ctx context.Context) (chan frame.Frame, error)
+}
+
+// sleep waits for specified duration, returns false if context was cancelled
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// frameInterval returns a delay between two frames for specified fps and replay speed,
+// zero speed means no delay (as fast as images are consumed)
+func frameInterval(fps int, speed float64) time.Duration {
+	if speed <= 0 {
+		return 0
+	}
+	if fps <= 0 {
+		fps = defaultFPS
+	}
+
+	return time.Duration(float64(time.Second) / (float64(fps) * speed))
+}
+
+// timeline stamps replayed images with capture time: one native frame interval apart from the start,
+// so the time doesn't depend on replay speed and is the same on every replay
+type timeline struct {
+	start    time.Time
+	interval time.Duration
+	images   int64
+}
+
+// next returns capture time of the next image
+func (t *timeline) next() time.Time {
+	captured := t.start.Add(time.Duration(t.images) * t.interval)
+	t.images++
+	return captured
+}
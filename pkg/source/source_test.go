@@ -0,0 +1,160 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/frame"
+)
+
+// recorded is modification time of fixture files
+var recorded = time.Date(2019, 3, 2, 18, 15, 3, 0, time.UTC)
+
+// testImages encodes small different images
+func testImages(t *testing.T, n int) [][]byte {
+	images := make([][]byte, n)
+	for i := range images {
+		img := image.NewGray(image.Rect(0, 0, 16, 8))
+		for j := range img.Pix {
+			img.Pix[j] = uint8(i*40 + j)
+		}
+		buf := new(bytes.Buffer)
+		if err := jpeg.Encode(buf, img, nil); err != nil {
+			t.Fatal(err)
+		}
+		images[i] = buf.Bytes()
+	}
+	return images
+}
+
+// writeFixture writes the file with fixed modification time
+func writeFixture(t *testing.T, path string, data []byte) {
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, recorded, recorded); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// replay reads up to limit frames from the source
+func replay(t *testing.T, s FrameSource, limit int) []frame.Frame {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ch, err := s.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := []frame.Frame{}
+	for f := range ch {
+		frames = append(frames, f)
+		if len(frames) == limit {
+			cancel()
+			break
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		t.Fatal("replay is not finished in time")
+	}
+	return frames
+}
+
+// checkReplay checks frames have the images, sequence numbers and capture time at 10 fps
+func checkReplay(t *testing.T, frames []frame.Frame, images [][]byte) {
+	t.Helper()
+	for i, f := range frames {
+		if f.Seq != uint64(i+1) {
+			t.Errorf("frame %d seq: %d", i, f.Seq)
+		}
+		if expected := recorded.Add(time.Duration(i) * 100 * time.Millisecond); !f.Time.Equal(expected) {
+			t.Errorf("frame %d time: %s, expected: %s", i, f.Time, expected)
+		}
+		if !bytes.Equal(f.Data, images[i%len(images)]) {
+			t.Errorf("frame %d differs from image %d", i, i%len(images))
+		}
+	}
+}
+
+// checkSameReplay checks two replays give the same frames
+func checkSameReplay(t *testing.T, first, second []frame.Frame) {
+	t.Helper()
+	if len(first) != len(second) {
+		t.Fatalf("frames: %d and %d", len(first), len(second))
+	}
+	for i := range first {
+		a, b := first[i], second[i]
+		if a.Seq != b.Seq || !a.Time.Equal(b.Time) || a.Source != b.Source || !bytes.Equal(a.Data, b.Data) {
+			t.Errorf("frame %d differs: %d %s %s, %d %s %s", i, a.Seq, a.Time, a.Source, b.Seq, b.Time, b.Source)
+		}
+	}
+}
+
+func TestFileReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	images := testImages(t, 5)
+	path := filepath.Join(dir, "session.mjpeg")
+	writeFixture(t, path, bytes.Join(images, nil))
+
+	// capture time doesn't depend on replay speed
+	first := replay(t, &File{Path: path, FPS: 10}, 0)
+	second := replay(t, &File{Path: path, FPS: 10, Speed: 20}, 0)
+	checkReplay(t, first, images)
+	checkSameReplay(t, first, second)
+	if len(first) != len(images) {
+		t.Errorf("frames: %d, expected: %d", len(first), len(images))
+	}
+
+	// timeline continues after the start over
+	looped := replay(t, &File{Path: path, FPS: 10, Loop: true}, 12)
+	checkReplay(t, looped, images)
+	if len(looped) != 12 {
+		t.Errorf("looped frames: %d, expected: 12", len(looped))
+	}
+
+	if _, err := (&File{Path: filepath.Join(dir, "missing.mjpeg")}).Start(context.Background()); err == nil {
+		t.Error("missing file is replayed")
+	}
+}
+
+func TestDirReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	images := testImages(t, 4)
+	for i, img := range images {
+		writeFixture(t, filepath.Join(dir, fmt.Sprintf("image-%02d.jpg", i)), img)
+	}
+	writeFixture(t, filepath.Join(dir, "notes.txt"), []byte("not an image"))
+
+	first := replay(t, &Dir{Path: dir, FPS: 10}, 0)
+	second := replay(t, &Dir{Path: dir, FPS: 10, Speed: 20}, 0)
+	checkReplay(t, first, images)
+	checkSameReplay(t, first, second)
+	if len(first) != len(images) {
+		t.Errorf("frames: %d, expected: %d", len(first), len(images))
+	}
+
+	looped := replay(t, &Dir{Path: dir, FPS: 10, Loop: true}, 10)
+	checkReplay(t, looped, images)
+	if len(looped) != 10 {
+		t.Errorf("looped frames: %d, expected: 10", len(looped))
+	}
+}
@@ -0,0 +1,175 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/frame"
+	"github.com/antonfisher/rpi-laser-cat-teaser/pkg/safety"
+)
+
+// URL pulls images from a remote MJPEG stream (multipart/x-mixed-replace),
+// like the one mjpeg.Server produces, and reconnects if the stream is interrupted
+type URL struct {
+	URL           string
+	RetryInterval time.Duration // delay before reconnection, 0 means default
+}
+
+// multipartReader reads parts of multipart/x-mixed-replace stream,
+// the boundary is accepted with and without leading "--" since not all servers follow RFC 2046
+type multipartReader struct {
+	reader   *bufio.Reader
+	boundary string
+}
+
+// isBoundary checks that line is a parts delimiter
+func (r *multipartReader) isBoundary(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	return string(line) == r.boundary ||
+		string(line) == "--"+r.boundary ||
+		string(line) == "--"+r.boundary+"--"
+}
+
+// nextPart returns the body of the next part
+func (r *multipartReader) nextPart() ([]byte, error) {
+	// skip everything before the boundary
+	for {
+		line, err := r.reader.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull {
+			return nil, err
+		}
+		if r.isBoundary(line) {
+			break
+		}
+	}
+
+	header, err := textproto.NewReader(r.reader).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	// read the exact number of bytes if length is known
+	if contentLength := header.Get("Content-Length"); contentLength != "" {
+		length, err := strconv.Atoi(contentLength)
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("wrong part Content-Length: '%s'", contentLength)
+		}
+		body := make([]byte, length)
+		_, err = io.ReadFull(r.reader, body)
+		return body, err
+	}
+
+	// otherwise read until the next boundary
+	body := new(bytes.Buffer)
+	for {
+		if r.nextIsBoundary() {
+			return bytes.TrimSuffix(body.Bytes(), []byte("\r\n")), nil
+		}
+		line, err := r.reader.ReadSlice('\n')
+		body.Write(line)
+		if err != nil && err != bufio.ErrBufferFull {
+			return nil, err
+		}
+	}
+}
+
+// nextIsBoundary checks that the next line is a parts delimiter without reading it
+func (r *multipartReader) nextIsBoundary() bool {
+	for _, boundary := range []string{"--" + r.boundary, r.boundary} {
+		peek, err := r.reader.Peek(len(boundary))
+		if err == nil && string(peek) == boundary {
+			return true
+		}
+	}
+	return false
+}
+
+// read sends stream images to the channel until the stream ends or fails
+func (s *URL) read(ctx context.Context, seq *frame.Sequence, ch chan frame.Frame) error {
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status: %s", res.Status)
+	}
+
+	mediaType, mediaParams, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") || mediaParams["boundary"] == "" {
+		return fmt.Errorf("not a multipart stream: '%s'", res.Header.Get("Content-Type"))
+	}
+
+	reader := &multipartReader{
+		reader:   bufio.NewReader(res.Body),
+		boundary: mediaParams["boundary"],
+	}
+
+	for {
+		imageBytes, err := reader.nextPart()
+		if err != nil {
+			return err
+		}
+
+		// try to send new image to the channel
+		select {
+		case ch <- seq.Next(imageBytes):
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			frame.Drops.Drop(frame.StageCapture)
+		}
+	}
+}
+
+// Start returns a channel of images
+func (s *URL) Start(ctx context.Context) (chan frame.Frame, error) {
+	fmt.Printf("[URL Source] pull %s\n", s.URL)
+
+	if !strings.HasPrefix(s.URL, "http://") && !strings.HasPrefix(s.URL, "https://") {
+		return nil, fmt.Errorf("[URL Source] unsupported URL: '%s'", s.URL)
+	}
+
+	retryInterval := s.RetryInterval
+	if retryInterval == 0 {
+		retryInterval = defaultRetryInterval
+	}
+
+	seq := &frame.Sequence{Source: s.URL}
+	ch := make(chan frame.Frame)
+
+	go func() {
+		defer safety.Guard()
+		defer close(ch)
+		for {
+			err := s.read(ctx, seq, ch)
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Printf("[URL Source] stream error: %s, reconnect in %s\n", err, retryInterval)
+			if !sleep(ctx, retryInterval) {
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
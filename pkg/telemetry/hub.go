@@ -0,0 +1,138 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	clientBufferSize  = 16
+	heartbeatInterval = 15 * time.Second
+)
+
+// Hub sends telemetry records to Server-Sent Events clients,
+// records are dropped for clients that are too slow to read them
+type Hub struct {
+	sync.Mutex
+	clients map[chan []byte]struct{}
+	closed  bool
+	doneCh  chan struct{}
+}
+
+func (h *Hub) addClient(client chan []byte) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.clients[client] = struct{}{}
+}
+
+func (h *Hub) removeClient(client chan []byte) {
+	h.Lock()
+	defer h.Unlock()
+
+	delete(h.clients, client)
+}
+
+func (h *Hub) logClients() {
+	fmt.Printf("[Telemetry] client count: %d\n", h.ClientCount())
+}
+
+// ClientCount returns number of connected clients
+func (h *Hub) ClientCount() int {
+	h.Lock()
+	defer h.Unlock()
+
+	return len(h.clients)
+}
+
+// Publish sends the record to all connected clients
+func (h *Hub) Publish(record Record) {
+	h.Lock()
+	defer h.Unlock()
+
+	if len(h.clients) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		fmt.Printf("[Telemetry] cannot encode record, error: %v\n", err)
+		return
+	}
+
+	for client := range h.clients {
+		select {
+		case client <- data:
+		default:
+		}
+	}
+}
+
+// HTTPHandler is a Server-Sent Events handler for HTTP server, each record is a "frame" event
+func (h *Hub) HTTPHandler(res http.ResponseWriter, req *http.Request) {
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		http.Error(res, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+
+	client := make(chan []byte, clientBufferSize)
+
+	h.addClient(client)
+	h.logClients()
+
+	defer h.logClients()
+	defer h.removeClient(client)
+
+	// send headers right away, the first record can take a while
+	fmt.Fprint(res, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		select {
+		case <-req.Context().Done():
+			return
+		case <-h.doneCh:
+			return
+		case <-heartbeat.C:
+			// keeps proxies from closing idle connection
+			_, err = fmt.Fprint(res, ": ping\n\n")
+		case data := <-client:
+			_, err = fmt.Fprintf(res, "event: frame\ndata: %s\n\n", data)
+		}
+		if err != nil { // likely connection is closed by client
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// Close disconnects all clients
+func (h *Hub) Close() {
+	h.Lock()
+	defer h.Unlock()
+
+	if !h.closed {
+		h.closed = true
+		close(h.doneCh)
+	}
+}
+
+// NewHub creates new telemetry hub
+func NewHub() *Hub {
+	return &Hub{
+		clients: map[chan []byte]struct{}{},
+		doneCh:  make(chan struct{}),
+	}
+}
@@ -0,0 +1,56 @@
+package telemetry
+
+import (
+	"time"
+)
+
+// Point is a position in percent [0.0-1.0]
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Detection is a detected motion blob, position and size are in percent of the image
+type Detection struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Pixels int     `json:"pixels"`
+}
+
+// Track is a motion track, position is in percent of the image, velocity is in percent per second
+type Track struct {
+	ID int     `json:"id"`
+	X  float64 `json:"x"`
+	Y  float64 `json:"y"`
+	VX float64 `json:"vx"`
+	VY float64 `json:"vy"`
+}
+
+// Record is telemetry of a single processed frame
+type Record struct {
+	Time   time.Time `json:"time"` // capture time
+	Source string    `json:"source"`
+	Seq    uint64    `json:"seq"`
+
+	Behavior   string      `json:"behavior"`
+	Detections []Detection `json:"detections"`
+	Tracks     []Track     `json:"tracks"`
+	Target     *Point      `json:"target,omitempty"` // motion the dot reacts to, image coordinates
+	Move       bool        `json:"move"`             // the dot is commanded to move on this frame
+
+	CommandedDot  Point   `json:"commanded_dot"` // servo field coordinates
+	Dot           *Point  `json:"dot,omitempty"` // found on the image, image coordinates
+	DotConfidence float64 `json:"dot_confidence"`
+	LaserOn       bool    `json:"laser_on"`
+
+	Latency        float64           `json:"latency_ms"`    // capture to the end of processing
+	ProcessingTime float64           `json:"processing_ms"` // decoding, detection, drawing
+	Dropped        map[string]uint64 `json:"dropped"`       // dropped frames by pipeline stage
+}
+
+// Milliseconds converts duration to fractional milliseconds
+func Milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
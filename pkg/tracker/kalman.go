@@ -0,0 +1,62 @@
+package tracker
+
+// kalman is a constant velocity Kalman filter for a single axis,
+// state is position and velocity, only position is measured
+type kalman struct {
+	p float64 // position
+	v float64 // velocity
+
+	// state covariance (symmetric)
+	p00 float64
+	p01 float64
+	p11 float64
+}
+
+func newKalman(position, measurementNoise float64) kalman {
+	return kalman{
+		p:   position,
+		p00: measurementNoise,
+		p11: 1, // velocity is unknown
+	}
+}
+
+// predict moves the state dt seconds ahead,
+// processNoise is a variance of acceleration
+func (k *kalman) predict(dt, processNoise float64) {
+	k.p += k.v * dt
+
+	// P = F*P*F' + Q, F = [1 dt; 0 1]
+	p00 := k.p00 + dt*(2*k.p01+dt*k.p11)
+	p01 := k.p01 + dt*k.p11
+	p11 := k.p11
+
+	// Q for random acceleration
+	dt2 := dt * dt
+	k.p00 = p00 + processNoise*dt2*dt2/4
+	k.p01 = p01 + processNoise*dt2*dt/2
+	k.p11 = p11 + processNoise*dt2
+}
+
+// update corrects the state with measured position
+func (k *kalman) update(z, measurementNoise float64) {
+	s := k.p00 + measurementNoise
+	if s == 0 {
+		return
+	}
+
+	k0 := k.p00 / s
+	k1 := k.p01 / s
+
+	y := z - k.p
+	k.p += k0 * y
+	k.v += k1 * y
+
+	// P = (I - K*H) * P, H = [1 0]
+	p00 := (1 - k0) * k.p00
+	p01 := (1 - k0) * k.p01
+	p11 := k.p11 - k1*k.p01
+
+	k.p00 = p00
+	k.p01 = p01
+	k.p11 = p11
+}
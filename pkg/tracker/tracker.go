@@ -0,0 +1,207 @@
+package tracker
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+var (
+	defaultMaxDistance      = 0.2
+	defaultTimeout          = time.Second
+	defaultProcessNoise     = 4.0   // acceleration variance, (percent/s^2)^2
+	defaultMeasurementNoise = 0.001 // position variance, percent^2
+	defaultTrailLength      = 32
+)
+
+// Detection is a detected object position in percent of the frame [0.0-1.0]
+type Detection struct {
+	X float64
+	Y float64
+}
+
+// Point is a track position in percent of the frame [0.0-1.0]
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Track is an object followed across frames
+type Track struct {
+	ID       int
+	X        float64   // smoothed position
+	Y        float64   // smoothed position
+	VX       float64   // velocity, percent of the frame per second
+	VY       float64   // velocity, percent of the frame per second
+	Hits     int       // number of associated detections
+	Created  time.Time // first detection time
+	LastSeen time.Time // last associated detection time
+	Trail    []Point   // last smoothed positions, the oldest first
+
+	kx kalman
+	ky kalman
+}
+
+// Speed returns track speed in percent of the frame per second
+func (t Track) Speed() float64 {
+	return math.Sqrt(t.VX*t.VX + t.VY*t.VY)
+}
+
+// Tracker associates detections across frames and assigns them stable track IDs
+type Tracker struct {
+	MaxDistance      float64       // max distance to associate detection with a track, percent of the frame
+	Timeout          time.Duration // lost track is removed after this time
+	ProcessNoise     float64       // acceleration variance, bigger value - faster reaction, less smoothing
+	MeasurementNoise float64       // detection position variance
+	TrailLength      int           // number of trail points to keep
+
+	tracks     []*Track
+	nextID     int
+	lastUpdate time.Time
+}
+
+func (t *Tracker) setDefaults() {
+	if t.MaxDistance == 0 {
+		t.MaxDistance = defaultMaxDistance
+	}
+	if t.Timeout == 0 {
+		t.Timeout = defaultTimeout
+	}
+	if t.ProcessNoise == 0 {
+		t.ProcessNoise = defaultProcessNoise
+	}
+	if t.MeasurementNoise == 0 {
+		t.MeasurementNoise = defaultMeasurementNoise
+	}
+	if t.TrailLength == 0 {
+		t.TrailLength = defaultTrailLength
+	}
+}
+
+type match struct {
+	track     int
+	detection int
+	distance  float64
+}
+
+// associate matches detections to tracks by nearest neighbour (greedy, the closest pairs first)
+func (t *Tracker) associate(detections []Detection) (trackDetections map[int]int, unmatched []int) {
+	matches := []match{}
+	for i, track := range t.tracks {
+		for j, d := range detections {
+			distance := math.Sqrt(math.Pow(track.X-d.X, 2) + math.Pow(track.Y-d.Y, 2))
+			if distance <= t.MaxDistance {
+				matches = append(matches, match{track: i, detection: j, distance: distance})
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].distance < matches[j].distance
+	})
+
+	trackDetections = map[int]int{}
+	matchedDetections := map[int]bool{}
+	for _, m := range matches {
+		if _, ok := trackDetections[m.track]; ok || matchedDetections[m.detection] {
+			continue
+		}
+		trackDetections[m.track] = m.detection
+		matchedDetections[m.detection] = true
+	}
+
+	for j := range detections {
+		if !matchedDetections[j] {
+			unmatched = append(unmatched, j)
+		}
+	}
+
+	return trackDetections, unmatched
+}
+
+// Update takes detections of a new frame and returns current tracks
+func (t *Tracker) Update(detections []Detection, now time.Time) []Track {
+	t.setDefaults()
+
+	// predict tracks positions for the current frame
+	dt := 0.0
+	if !t.lastUpdate.IsZero() {
+		dt = now.Sub(t.lastUpdate).Seconds()
+	}
+	t.lastUpdate = now
+	for _, track := range t.tracks {
+		track.kx.predict(dt, t.ProcessNoise)
+		track.ky.predict(dt, t.ProcessNoise)
+		track.X = track.kx.p
+		track.Y = track.ky.p
+	}
+
+	trackDetections, unmatched := t.associate(detections)
+
+	// correct matched tracks, remove lost ones
+	tracks := t.tracks[:0]
+	for i, track := range t.tracks {
+		if j, ok := trackDetections[i]; ok {
+			track.kx.update(detections[j].X, t.MeasurementNoise)
+			track.ky.update(detections[j].Y, t.MeasurementNoise)
+			track.Hits++
+			track.LastSeen = now
+		} else if now.Sub(track.LastSeen) > t.Timeout {
+			continue
+		}
+
+		track.X = track.kx.p
+		track.Y = track.ky.p
+		track.VX = track.kx.v
+		track.VY = track.ky.v
+		track.Trail = append(track.Trail, Point{X: track.X, Y: track.Y})
+		if len(track.Trail) > t.TrailLength {
+			track.Trail = track.Trail[len(track.Trail)-t.TrailLength:]
+		}
+
+		tracks = append(tracks, track)
+	}
+	t.tracks = tracks
+
+	// start new tracks
+	for _, j := range unmatched {
+		t.nextID++
+		t.tracks = append(t.tracks, &Track{
+			ID:       t.nextID,
+			X:        detections[j].X,
+			Y:        detections[j].Y,
+			Hits:     1,
+			Created:  now,
+			LastSeen: now,
+			Trail:    []Point{{X: detections[j].X, Y: detections[j].Y}},
+			kx:       newKalman(detections[j].X, t.MeasurementNoise),
+			ky:       newKalman(detections[j].Y, t.MeasurementNoise),
+		})
+	}
+
+	return t.Tracks()
+}
+
+// Tracks returns a copy of current tracks
+func (t *Tracker) Tracks() []Track {
+	tracks := make([]Track, len(t.tracks))
+	for i, track := range t.tracks {
+		tracks[i] = *track
+		tracks[i].Trail = append([]Point(nil), track.Trail...)
+	}
+
+	return tracks
+}
+
+// NearestTrack returns the track closest to the point
+func NearestTrack(tracks []Track, x, y float64) (track Track, ok bool) {
+	minDistance := math.Inf(1)
+	for _, t := range tracks {
+		d := math.Pow(t.X-x, 2) + math.Pow(t.Y-y, 2)
+		if d < minDistance {
+			minDistance = d
+			track = t
+		}
+	}
+	return track, len(tracks) > 0
+}
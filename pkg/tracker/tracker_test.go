@@ -0,0 +1,107 @@
+package tracker
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2019, 3, 2, 18, 15, 3, 0, time.UTC)
+
+// frameTime returns time of the frame at 25 fps
+func frameTime(i int) time.Time {
+	return start.Add(time.Duration(i) * 40 * time.Millisecond)
+}
+
+func TestKalmanConstantVelocity(t *testing.T) {
+	tracker := &Tracker{}
+
+	// the cat runs across the room
+	vx, vy := 0.3, -0.1
+	var tracks []Track
+	for i := 0; i < 50; i++ {
+		seconds := float64(i) * 0.04
+		tracks = tracker.Update([]Detection{{X: 0.1 + vx*seconds, Y: 0.8 + vy*seconds}}, frameTime(i))
+	}
+
+	if len(tracks) != 1 {
+		t.Fatalf("tracks: %+v", tracks)
+	}
+	track := tracks[0]
+	if math.Abs(track.VX-vx) > 0.01 || math.Abs(track.VY-vy) > 0.01 {
+		t.Errorf("velocity: (%.3f, %.3f), expected: (%.3f, %.3f)", track.VX, track.VY, vx, vy)
+	}
+	if x, y := 0.1+vx*1.96, 0.8+vy*1.96; math.Abs(track.X-x) > 0.005 || math.Abs(track.Y-y) > 0.005 {
+		t.Errorf("position: (%.3f, %.3f), expected: (%.3f, %.3f)", track.X, track.Y, x, y)
+	}
+	if math.Abs(track.Speed()-math.Sqrt(vx*vx+vy*vy)) > 0.01 {
+		t.Errorf("speed: %.3f", track.Speed())
+	}
+	if track.ID != 1 || track.Hits != 50 || len(track.Trail) != defaultTrailLength {
+		t.Errorf("track: id: %d, hits: %d, trail: %d", track.ID, track.Hits, len(track.Trail))
+	}
+}
+
+func TestKalmanNoise(t *testing.T) {
+	tracker := &Tracker{}
+
+	// the sitting cat, detections jitter around the position
+	jitter := []float64{0.01, -0.01, 0.005, -0.005, 0}
+	var tracks []Track
+	for i := 0; i < 50; i++ {
+		d := jitter[i%len(jitter)]
+		tracks = tracker.Update([]Detection{{X: 0.5 + d, Y: 0.5 - d}}, frameTime(i))
+	}
+
+	if len(tracks) != 1 {
+		t.Fatalf("tracks: %+v", tracks)
+	}
+	if math.Abs(tracks[0].X-0.5) > 0.01 || math.Abs(tracks[0].Y-0.5) > 0.01 || tracks[0].Speed() > 0.2 {
+		t.Errorf("track: (%.3f, %.3f), speed: %.3f", tracks[0].X, tracks[0].Y, tracks[0].Speed())
+	}
+}
+
+func TestTrackIDs(t *testing.T) {
+	tracker := &Tracker{Timeout: 200 * time.Millisecond}
+
+	// two cats run in parallel, detections come in any order
+	for i := 0; i < 10; i++ {
+		x := 0.1 + float64(i)*0.01
+		detections := []Detection{{X: x, Y: 0.2}, {X: x, Y: 0.7}}
+		if i%2 == 1 {
+			detections[0], detections[1] = detections[1], detections[0]
+		}
+		tracks := tracker.Update(detections, frameTime(i))
+		if len(tracks) != 2 {
+			t.Fatalf("frame %d tracks: %+v", i, tracks)
+		}
+		for _, track := range tracks {
+			if (track.ID == 1) != (track.Y < 0.5) {
+				t.Fatalf("frame %d: track %d switched the cat: %+v", i, track.ID, track)
+			}
+		}
+	}
+
+	// the first cat hides, its track is kept until timeout
+	tracks := tracker.Update([]Detection{{X: 0.2, Y: 0.7}}, frameTime(12))
+	if len(tracks) != 2 {
+		t.Errorf("tracks before timeout: %d, expected: 2", len(tracks))
+	}
+	tracks = tracker.Update([]Detection{{X: 0.21, Y: 0.7}}, frameTime(16))
+	if len(tracks) != 1 || tracks[0].ID != 2 {
+		t.Errorf("tracks after timeout: %+v", tracks)
+	}
+
+	// a new cat gets a new ID
+	tracks = tracker.Update([]Detection{{X: 0.22, Y: 0.7}, {X: 0.9, Y: 0.1}}, frameTime(17))
+	if len(tracks) != 2 || tracks[1].ID != 3 {
+		t.Errorf("tracks: %+v", tracks)
+	}
+
+	if track, ok := NearestTrack(tracks, 0.8, 0.2); !ok || track.ID != 3 {
+		t.Errorf("nearest track: %+v", track)
+	}
+	if _, ok := NearestTrack(nil, 0.8, 0.2); ok {
+		t.Error("nearest track is found without tracks")
+	}
+}